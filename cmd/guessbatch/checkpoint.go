@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// checkpointEntry is one line of the checkpoint file
+type checkpointEntry struct {
+	Handle string    `json:"handle"`
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// checkpoint records each handle once its result is written, one JSON line
+// at a time, so an interrupted batch resumes where it stopped. A nil
+// checkpoint records nothing.
+type checkpoint struct {
+	path string
+	file *os.File
+	done map[string]string // lower-case handle -> status
+}
+
+// openCheckpoint reads the handles an earlier run finished and opens the file
+// for appending. A line cut short by a crash is ignored.
+func openCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{path: path, done: make(map[string]string)}
+
+	if file, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry checkpointEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Handle != "" {
+				c.done[strings.ToLower(entry.Handle)] = entry.Status
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open checkpoint %s: %w", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint %s: %w", path, err)
+	}
+	c.file = file
+	return c, nil
+}
+
+// status returns how an earlier run finished a handle, if it did
+func (c *checkpoint) status(handle string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	status, ok := c.done[strings.ToLower(handle)]
+	return status, ok
+}
+
+// record marks a handle as finished
+func (c *checkpoint) record(handle string, status string) error {
+	if c == nil {
+		return nil
+	}
+	line, err := json.Marshal(checkpointEntry{Handle: handle, Status: status, At: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint %s: %w", c.path, err)
+	}
+	c.done[strings.ToLower(handle)] = status
+	return nil
+}
+
+// Close closes the checkpoint file
+func (c *checkpoint) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.file.Close()
+}
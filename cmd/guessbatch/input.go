@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// handlePattern matches a valid Twitter handle
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+
+// headerNames are first-line column names skipped when the input is a spreadsheet export
+var headerNames = map[string]bool{
+	"handle":         true,
+	"twitter_handle": true,
+	"twitter":        true,
+	"username":       true,
+	"user":           true,
+}
+
+// readHandles reads one Twitter handle per line. Blank lines and lines starting
+// with # are skipped, and for CSV or TSV exports only the first column is used.
+// Handles may be written as name, @name or a twitter.com or x.com profile URL.
+// Duplicates are dropped, ignoring case as Twitter does.
+func readHandles(r io.Reader) ([]string, error) {
+	var handles []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.IndexAny(line, ",\t;"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.Trim(line, `" `)
+
+		if lineNumber == 1 && headerNames[strings.ToLower(line)] {
+			continue
+		}
+		handle := normalizeHandle(line)
+		if !handlePattern.MatchString(handle) {
+			log.Warnf("Skipping line %d, %q is not a Twitter handle", lineNumber, line)
+			continue
+		}
+		if seen[strings.ToLower(handle)] {
+			continue
+		}
+		seen[strings.ToLower(handle)] = true
+		handles = append(handles, handle)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read handles: %w", err)
+	}
+	return handles, nil
+}
+
+// normalizeHandle strips the @ and profile URL around a handle
+func normalizeHandle(value string) string {
+	for _, prefix := range []string{"https://", "http://", "www.", "mobile."} {
+		value = strings.TrimPrefix(value, prefix)
+	}
+	for _, host := range []string{"twitter.com/", "x.com/"} {
+		if rest, found := strings.CutPrefix(value, host); found {
+			value, _, _ = strings.Cut(rest, "/")
+			value, _, _ = strings.Cut(value, "?")
+			break
+		}
+	}
+	return strings.TrimPrefix(value, "@")
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"wallet-guesser/internal/avoidlist"
+	"wallet-guesser/internal/blockchain"
+	"wallet-guesser/internal/config"
+	"wallet-guesser/internal/game"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/twitter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// guess is a handle finished by a worker, on its way to the writer
+type guess struct {
+	record    record
+	cancelled bool // the batch was interrupted mid-guess, so the handle is left for the next run
+}
+
+func main() {
+	// Parse command line arguments
+	flagSet := flag.NewFlagSet("guessbatch", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage: guessbatch [flags] [handles file]\n\n")
+		fmt.Fprintf(flagSet.Output(), "Guesses the wallets of a list of Twitter handles, one per line, read from a file or stdin.\n")
+		fmt.Fprintf(flagSet.Output(), "The server's configuration is read from the environment, .env and -config.\n\n")
+		flagSet.PrintDefaults()
+	}
+	inputFile := flagSet.String("input", "-", "File of handles to guess, - for stdin")
+	outputFile := flagSet.String("output", "", "File to write results to (default: stdout)")
+	format := flagSet.String("format", "", "Output format, jsonl or csv (default: from the output file's extension, otherwise jsonl)")
+	concurrency := flagSet.Int("concurrency", 0, "Number of guesses run at once (default: GUESS_WORKERS)")
+	timeout := flagSet.Duration("timeout", 0, "Maximum duration of a single guess (default: GUESS_TIMEOUT)")
+	checkpointFile := flagSet.String("checkpoint", "", "File recording finished handles so an interrupted batch resumes (default: <output>.checkpoint, none for stdout)")
+	retryErrors := flagSet.Bool("retry-errors", false, "Guess handles again that failed in an earlier run, including guesses that timed out; guesses cut short by an interrupt are always guessed again")
+	configFile := flagSet.String("config", "", "Path to a YAML or TOML config file (default: CONFIG_FILE)")
+	_ = flagSet.Parse(os.Args[1:])
+	if flagSet.NArg() > 0 {
+		// Accept flags after the handles file too
+		*inputFile = flagSet.Arg(0)
+		_ = flagSet.Parse(flagSet.Args()[1:])
+		if flagSet.NArg() > 0 {
+			flagSet.Usage()
+			os.Exit(2)
+		}
+	}
+
+	// Load the server's configuration; results go to stdout so everything else goes to stderr
+	var configArgs []string
+	if *configFile != "" {
+		configArgs = []string{"-config", *configFile}
+	}
+	cfg, err := config.Load(configArgs)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if err := logging.Configure(cfg.LogFormat, cfg.Debug); err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	log.SetOutput(os.Stderr)
+	logging.RegisterSecret(cfg.ApifyToken)
+	logging.RegisterSecret(cfg.DuneApiKey)
+
+	if *concurrency <= 0 {
+		*concurrency = cfg.GuessWorkers
+	}
+	if *timeout <= 0 {
+		*timeout = cfg.GuessTimeout
+	}
+	outputFormat, err := formatFor(*format, *outputFile)
+	if err != nil {
+		log.Fatalf("Invalid -format: %v", err)
+	}
+	if *checkpointFile == "" && *outputFile != "" {
+		*checkpointFile = *outputFile + ".checkpoint"
+	}
+
+	handles, err := readInput(*inputFile)
+	if err != nil {
+		log.Fatalf("Failed to read handles: %v", err)
+	}
+
+	// Skip the handles an earlier run finished
+	var progress *checkpoint
+	if *checkpointFile != "" {
+		progress, err = openCheckpoint(*checkpointFile)
+		if err != nil {
+			log.Fatalf("Failed to open checkpoint: %v", err)
+		}
+		defer progress.Close()
+	}
+	pending := make([]string, 0, len(handles))
+	for _, handle := range handles {
+		status, done := progress.status(handle)
+		if done && (status != statusError || !*retryErrors) {
+			continue
+		}
+		pending = append(pending, handle)
+	}
+	if skipped := len(handles) - len(pending); skipped > 0 {
+		log.Infof("Resuming from %s, skipping %d of %d handles already guessed", *checkpointFile, skipped, len(handles))
+	}
+	if len(pending) == 0 {
+		log.Infof("Nothing to guess")
+		compact(*outputFile, outputFormat)
+		return
+	}
+
+	output, newOutput, err := openOutput(*outputFile)
+	if err != nil {
+		log.Fatalf("Failed to open output: %v", err)
+	}
+	defer output.Close()
+	writer, err := newResultWriter(output, outputFormat, newOutput)
+	if err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+
+	walletGuesser := newWalletGuesser(cfg)
+
+	// Stop starting guesses on interrupt; guesses cut short are not recorded and rerun on resume
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Infof("Guessing %d handles with %d workers", len(pending), *concurrency)
+	results := runGuesses(ctx, walletGuesser, pending, *concurrency, *timeout)
+
+	counts := make(map[string]int)
+	finished := 0
+	var writeErr error
+	for result := range results {
+		if result.cancelled || writeErr != nil {
+			continue
+		}
+		rec := result.record
+		if err := writer.Write(rec); err != nil {
+			writeErr = fmt.Errorf("failed to write result for @%s: %w", rec.Handle, err)
+			stop()
+			continue
+		}
+		if err := progress.record(rec.Handle, rec.Status); err != nil {
+			writeErr = err
+			stop()
+			continue
+		}
+
+		finished++
+		counts[rec.Status]++
+		switch rec.Status {
+		case statusFound:
+			log.Infof("[%d/%d] @%s: %d addresses, confidence %d", finished, len(pending), rec.Handle, len(rec.Addresses), rec.Confidence)
+		case statusNotFound:
+			log.Infof("[%d/%d] @%s: no addresses found", finished, len(pending), rec.Handle)
+		default:
+			log.Warnf("[%d/%d] @%s: %s", finished, len(pending), rec.Handle, rec.Error)
+		}
+	}
+
+	log.Infof("Guessed %d handles: %d found, %d not found, %d failed",
+		finished, counts[statusFound], counts[statusNotFound], counts[statusError])
+
+	output.Close()
+	compact(*outputFile, outputFormat)
+	if writeErr != nil {
+		log.Fatalf("Stopped: %v", writeErr)
+	}
+	if remaining := len(pending) - finished; remaining > 0 {
+		if *checkpointFile != "" {
+			log.Warnf("Interrupted with %d handles left, run the same command again to resume", remaining)
+		} else {
+			log.Warnf("Interrupted with %d handles left", remaining)
+		}
+		os.Exit(1)
+	}
+}
+
+// readInput reads the handles from a file, or stdin for -
+func readInput(path string) ([]string, error) {
+	if path == "-" {
+		return readHandles(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readHandles(file)
+}
+
+// openOutput opens the output for appending, so a resumed batch adds to the
+// results of the one before, and reports whether it is new or empty
+func openOutput(path string) (io.WriteCloser, bool, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, true, nil
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, false, err
+	}
+	if info.Size() == 0 {
+		return file, true, nil
+	}
+
+	// End a line cut short by a crash, so the next result starts a line of its own
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		file.Close()
+		return nil, false, err
+	}
+	if last[0] != '\n' {
+		if _, err := file.Write([]byte("\n")); err != nil {
+			file.Close()
+			return nil, false, err
+		}
+	}
+	return file, false, nil
+}
+
+// compact leaves one row per handle in an output file. On stdout every row is
+// kept, and the last one written for a handle wins.
+func compact(outputFile string, format string) {
+	if outputFile == "" || outputFile == "-" {
+		return
+	}
+	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
+		return
+	}
+	if removed, err := compactOutput(outputFile, format); err != nil {
+		log.Errorf("Failed to remove superseded results from %s: %v", outputFile, err)
+	} else if removed > 0 {
+		log.Infof("Removed %d superseded results from %s", removed, outputFile)
+	}
+}
+
+// nopCloser keeps stdout open when the output is closed
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// newWalletGuesser builds the guesser the way the server does. The holder and
+// result caches are read but not written, so a batch never clobbers the
+// server's files.
+func newWalletGuesser(cfg *config.Config) *game.WalletGuesser {
+	avoidListSvc := avoidlist.NewService(cfg.DuneApiKey, cfg.AvoidListPath,
+		avoidlist.WithDuneQueryID(cfg.DuneQueryID),
+		avoidlist.WithOverridesPath(cfg.AvoidListOverridesPath),
+	)
+	if err := avoidListSvc.LoadFromFile(); err != nil {
+		log.Warnf("Could not load avoid list, will guess without it: %v", err)
+	}
+	if err := avoidListSvc.LoadOverrides(); err != nil {
+		log.Warnf("Could not load avoid list overrides, will guess without them: %v", err)
+	}
+
+	twitterClient := twitter.NewClient(
+		twitter.WithApifyToken(cfg.ApifyToken),
+		twitter.WithActorURL(cfg.ApifyActorURL),
+		twitter.WithTimeout(int(cfg.ApifyTimeout/time.Second)),
+		twitter.WithWebsiteScanning(cfg.ScanWebsites),
+	)
+
+	blockchainClient := blockchain.NewClient(cfg.SolanaRpcEndpoint, avoidListSvc,
+		blockchain.WithTimeout(cfg.RpcTimeout),
+		blockchain.WithProgramOwners(cfg.IncludeProgramOwners),
+	)
+	if _, err := blockchainClient.LoadHolderCache(cfg.HolderCachePath); err != nil {
+		log.Warnf("Could not load holder cache: %v", err)
+	}
+
+	walletGuesser := game.NewWalletGuesser(twitterClient, blockchainClient, avoidListSvc,
+		game.WithFollowLimit(cfg.FollowLimit),
+		game.WithMaxResults(cfg.MaxResults),
+	)
+	if _, err := walletGuesser.LoadResultCache(cfg.ResultCachePath); err != nil {
+		log.Warnf("Could not load result cache: %v", err)
+	}
+	return walletGuesser
+}
+
+// runGuesses guesses the handles with a pool of workers, sending each result
+// as it finishes. The channel is closed once every worker has stopped.
+func runGuesses(ctx context.Context, walletGuesser *game.WalletGuesser, handles []string, workers int, timeout time.Duration) <-chan guess {
+	queue := make(chan string)
+	results := make(chan guess)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for handle := range queue {
+				results <- guessHandle(ctx, walletGuesser, handle, timeout)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(queue)
+		for _, handle := range handles {
+			select {
+			case <-ctx.Done():
+				return
+			case queue <- handle:
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// guessHandle runs a single guess with its own timeout
+func guessHandle(ctx context.Context, walletGuesser *game.WalletGuesser, handle string, timeout time.Duration) guess {
+	guessCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := walletGuesser.GuessWallet(guessCtx, handle, nil)
+	if err != nil && ctx.Err() != nil {
+		return guess{cancelled: true}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("guess timed out after %s", timeout)
+	}
+	return guess{record: newRecord(handle, result, err, time.Since(start))}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet-guesser/internal/domain"
+)
+
+// Output formats
+const (
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+)
+
+// Record statuses. Failed guesses are retried on resume only with -retry-errors.
+const (
+	statusFound    = "found"     // at least one address was guessed
+	statusNotFound = "not_found" // the guess ran but found no address
+	statusError    = "error"     // the guess failed
+)
+
+// record is the outcome of guessing one handle
+type record struct {
+	Handle     string               `json:"handle"`
+	Status     string               `json:"status"`
+	Confidence int                  `json:"confidence"`
+	Addresses  []string             `json:"addresses"`
+	Evidence   []string             `json:"evidence"` // why each address was guessed, in the same order
+	Excluded   []domain.AvoidReason `json:"excluded,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	DurationMs int64                `json:"durationMs"`
+	GuessedAt  time.Time            `json:"guessedAt"`
+}
+
+// newRecord builds the record of a finished guess
+func newRecord(handle string, result *domain.WalletGuessResult, err error, duration time.Duration) record {
+	rec := record{
+		Handle:     handle,
+		Addresses:  []string{},
+		Evidence:   []string{},
+		DurationMs: duration.Milliseconds(),
+		GuessedAt:  time.Now().UTC(),
+	}
+	switch {
+	case err != nil:
+		rec.Status = statusError
+		rec.Error = err.Error()
+	case len(result.Addresses) == 0:
+		rec.Status = statusNotFound
+		rec.Excluded = result.Excluded
+	default:
+		rec.Status = statusFound
+		rec.Confidence = result.Confidence
+		rec.Addresses = result.Addresses
+		rec.Evidence = result.Sources
+		rec.Excluded = result.Excluded
+	}
+	return rec
+}
+
+// resultWriter writes records to the output as they finish
+type resultWriter interface {
+	Write(rec record) error
+}
+
+// formatFor picks the output format from the -format flag or the output file's extension
+func formatFor(format string, outputFile string) (string, error) {
+	if format == "" {
+		if strings.EqualFold(filepath.Ext(outputFile), ".csv") {
+			return formatCSV, nil
+		}
+		return formatJSONL, nil
+	}
+	switch format {
+	case formatJSONL, formatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format %q (expected %s or %s)", format, formatJSONL, formatCSV)
+	}
+}
+
+// newResultWriter creates a writer for a format. The CSV header is only
+// written to a new output, so resumed runs append to the same table.
+func newResultWriter(w io.Writer, format string, newOutput bool) (resultWriter, error) {
+	if format == formatJSONL {
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	}
+
+	writer := &csvWriter{writer: csv.NewWriter(w)}
+	if newOutput {
+		if err := writer.write(csvHeader); err != nil {
+			return nil, err
+		}
+	}
+	return writer, nil
+}
+
+// jsonlWriter writes one JSON object per line
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(rec record) error {
+	return w.encoder.Encode(rec)
+}
+
+// csvHeader names the CSV columns. Addresses and exclusions are joined with
+// semicolons and evidence with " | ", in the order of the addresses.
+var csvHeader = []string{"handle", "status", "confidence", "top_address", "addresses", "evidence", "excluded", "error", "duration_ms", "guessed_at"}
+
+// csvWriter writes one row per handle
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(rec record) error {
+	topAddress := ""
+	if len(rec.Addresses) > 0 {
+		topAddress = rec.Addresses[0]
+	}
+	excluded := make([]string, 0, len(rec.Excluded))
+	for _, reason := range rec.Excluded {
+		excluded = append(excluded, fmt.Sprintf("%s (%s)", reason.Address, reason.Category))
+	}
+
+	return w.write([]string{
+		rec.Handle,
+		rec.Status,
+		strconv.Itoa(rec.Confidence),
+		topAddress,
+		strings.Join(rec.Addresses, ";"),
+		strings.Join(rec.Evidence, " | "),
+		strings.Join(excluded, ";"),
+		rec.Error,
+		strconv.FormatInt(rec.DurationMs, 10),
+		rec.GuessedAt.Format(time.RFC3339),
+	})
+}
+
+// write writes a row and flushes it, so an interruption loses nothing already guessed
+func (w *csvWriter) write(row []string) error {
+	if err := w.writer.Write(row); err != nil {
+		return err
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// compactOutput rewrites an output file keeping only the last row written for
+// each handle, in the place the handle first appeared. A handle guessed again
+// with -retry-errors, or written twice because a run stopped between the
+// output and the checkpoint, is left with its latest result. Lines that cannot
+// be read, such as one cut short by a crash, are dropped. It returns the
+// number of rows removed.
+func compactOutput(path string, format string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var header []string
+	var rows [][]string
+	if format == formatCSV {
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		rows, err = reader.ReadAll()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if len(rows) > 0 {
+			header, rows = rows[0], rows[1:]
+		}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) != "" {
+				rows = append(rows, []string{line})
+			}
+		}
+	}
+
+	// Keep the latest row per handle at the position of the handle's first row
+	positions := make(map[string]int)
+	var kept [][]string
+	for _, row := range rows {
+		handle := rowHandle(row, format)
+		if handle == "" {
+			continue
+		}
+		if position, ok := positions[handle]; ok {
+			kept[position] = row
+			continue
+		}
+		positions[handle] = len(kept)
+		kept = append(kept, row)
+	}
+	removed := len(rows) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	var buffer bytes.Buffer
+	if format == formatCSV {
+		writer := csv.NewWriter(&buffer)
+		if header != nil {
+			writer.Write(header)
+		}
+		writer.WriteAll(kept)
+		if err := writer.Error(); err != nil {
+			return 0, err
+		}
+	} else {
+		for _, row := range kept {
+			buffer.WriteString(row[0])
+			buffer.WriteByte('\n')
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buffer.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return removed, nil
+}
+
+// rowHandle returns the lower-case handle of an output row, or "" if the row cannot be read
+func rowHandle(row []string, format string) string {
+	if format == formatCSV {
+		if len(row) != len(csvHeader) {
+			return ""
+		}
+		return strings.ToLower(row[0])
+	}
+
+	var rec record
+	if json.Unmarshal([]byte(row[0]), &rec) != nil {
+		return ""
+	}
+	return strings.ToLower(rec.Handle)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"wallet-guesser/internal/api/protocol"
+)
+
+func main() {
+	// Parse command line arguments
+	var outputFile string
+	flag.StringVar(&outputFile, "output", "", "Path to write the protocol spec to (default: stdout)")
+	flag.Parse()
+
+	spec, err := protocol.MarshalSpec()
+	if err != nil {
+		log.Fatalf("Failed to generate protocol spec: %v", err)
+	}
+
+	if outputFile == "" {
+		fmt.Print(string(spec))
+		return
+	}
+
+	if err := os.WriteFile(outputFile, spec, 0644); err != nil {
+		log.Fatalf("Failed to write protocol spec: %v", err)
+	}
+	log.Infof("Wrote protocol spec to %s", outputFile)
+}
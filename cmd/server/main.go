@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
-
+	"os/signal"
+	"syscall"
+	"time"
+
+	"wallet-guesser/internal/api/admin"
+	"wallet-guesser/internal/api/health"
+	"wallet-guesser/internal/api/response"
+	"wallet-guesser/internal/api/rest"
 	"wallet-guesser/internal/api/websocket"
 	"wallet-guesser/internal/avoidlist"
 	"wallet-guesser/internal/blockchain"
+	"wallet-guesser/internal/buildinfo"
 	"wallet-guesser/internal/config"
 	"wallet-guesser/internal/game"
+	"wallet-guesser/internal/jobs"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/tracing"
 	"wallet-guesser/internal/twitter"
+	"wallet-guesser/internal/verification"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,21 +39,45 @@ func main() {
 	})
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Set log level based on debug setting
-	if cfg.Debug {
-		log.SetLevel(log.DebugLevel)
-	} else {
-		log.SetLevel(log.InfoLevel)
+	// Apply the configured log format and level, and keep credentials out of the logs
+	if err := logging.Configure(cfg.LogFormat, cfg.Debug); err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	logging.RegisterSecret(cfg.ApifyToken)
+	logging.RegisterSecret(cfg.DuneApiKey)
+	logging.RegisterSecret(cfg.AdminToken)
+	build := buildinfo.Get()
+	log.Infof("Starting Wallet Guesser server %s (commit %s)...", build.Version, build.Commit)
+	if cfg.ConfigFile != "" {
+		log.Infof("Loaded configuration from %s", cfg.ConfigFile)
 	}
-	log.Info("Starting Wallet Guesser server...")
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Errorf("Failed to flush traces: %v", err)
+		}
+	}()
 
 	// Initialize avoid list service
-	avoidListSvc := avoidlist.NewService(cfg.DuneApiKey, cfg.AvoidListPath)
+	avoidListSvc := avoidlist.NewService(cfg.DuneApiKey, cfg.AvoidListPath,
+		avoidlist.WithDuneQueryID(cfg.DuneQueryID),
+		avoidlist.WithDuneExecution(cfg.DuneExecute),
+		avoidlist.WithRefreshInterval(cfg.AvoidListRefreshInterval),
+		avoidlist.WithOverridesPath(cfg.AvoidListOverridesPath),
+	)
 	if err := avoidListSvc.LoadFromFile(); err != nil {
 		log.Warnf("Could not load avoid list, will start with empty list: %v", err)
 	} else {
@@ -45,29 +85,74 @@ func main() {
 		log.Infof("Loaded avoid list with %d entries, last updated at %s",
 			stats["totalEntries"], stats["lastUpdated"])
 	}
+	if err := avoidListSvc.LoadOverrides(); err != nil {
+		log.Warnf("Could not load avoid list overrides, will start without them: %v", err)
+	}
 
 	// Initialize Twitter client
 	twitterClient := twitter.NewClient(
 		twitter.WithApifyToken(cfg.ApifyToken),
+		twitter.WithActorURL(cfg.ApifyActorURL),
+		twitter.WithTimeout(int(cfg.ApifyTimeout/time.Second)),
+		twitter.WithWebsiteScanning(cfg.ScanWebsites),
 	)
 
 	// Initialize Blockchain client
-	blockchainClient := blockchain.NewClient(cfg.SolanaRpcEndpoint, avoidListSvc)
+	blockchainClient := blockchain.NewClient(cfg.SolanaRpcEndpoint, avoidListSvc,
+		blockchain.WithTimeout(cfg.RpcTimeout),
+		blockchain.WithProgramOwners(cfg.IncludeProgramOwners),
+	)
+	if loaded, err := blockchainClient.LoadHolderCache(cfg.HolderCachePath); err != nil {
+		log.Warnf("Could not load holder cache, will start with empty cache: %v", err)
+	} else if loaded > 0 {
+		log.Infof("Loaded cached holders of %d tokens", loaded)
+	}
 
-	// Initialize the wallet guesser
-	walletGuesser := game.NewWalletGuesser(twitterClient, blockchainClient, avoidListSvc)
+	// Initialize the wallet guesser with the results saved at the last shutdown
+	walletGuesser := game.NewWalletGuesser(twitterClient, blockchainClient, avoidListSvc,
+		game.WithFollowLimit(cfg.FollowLimit),
+		game.WithMaxResults(cfg.MaxResults),
+	)
+	if loaded, err := walletGuesser.LoadResultCache(cfg.ResultCachePath); err != nil {
+		log.Warnf("Could not load result cache, will start with empty cache: %v", err)
+	} else if loaded > 0 {
+		log.Infof("Loaded %d cached guess results", loaded)
+	}
+
+	// Initialize wallet ownership verification
+	verificationSvc := verification.NewService(verification.DefaultChallengeTTL)
+
+	// Initialize the guess job queue shared by the game and the REST API
+	jobManager := jobs.NewManager(walletGuesser, jobs.Options{
+		Workers:    cfg.GuessWorkers,
+		MaxQueued:  cfg.GuessQueueSize,
+		JobTimeout: cfg.GuessTimeout,
+		StorePath:  cfg.JobStorePath,
+	})
 
 	// Initialize API handlers
-	wsHandler := websocket.NewHandler(walletGuesser)
+	sessions := game.NewSessionStore(game.DefaultSessionTTL)
+	sessions.SetThresholds(game.Thresholds{Confident: cfg.ConfidentThreshold, Uncertain: cfg.UncertainThreshold})
+	wsHandler := websocket.NewHandler(jobManager, verificationSvc, sessions)
+	restHandler := rest.NewHandler(jobManager)
+	adminHandler := admin.NewHandler(cfg.AdminToken, walletGuesser, avoidListSvc)
+	healthHandler := health.NewHandler(blockchainClient, avoidListSvc, cfg.ApifyToken)
 
 	// Set up WebSocket endpoint
 	http.HandleFunc("/ws", wsHandler.HandleWebSocket)
 
+	// Set up REST API for scripted guesses
+	restHandler.Register(http.DefaultServeMux)
+
+	// Set up admin API for caches and the avoid list
+	adminHandler.Register(http.DefaultServeMux)
+	if cfg.AdminToken == "" {
+		log.Info("ADMIN_TOKEN not set, admin API is disabled")
+	}
+
 	// Set up CORS headers for development
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		response.CORS(w)
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -78,16 +163,84 @@ func main() {
 		http.NotFound(w, r)
 	})
 
-	// Create status endpoint
-	http.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"ok","version":"1.0.0"}`))
-	})
+	// Set up liveness, readiness and status endpoints
+	healthHandler.Register(http.DefaultServeMux)
+
+	// Expose Prometheus metrics
+	http.Handle("/metrics", promhttp.Handler())
 
 	// Start the server
-	serverAddr := fmt.Sprintf(":%d", cfg.Port)
-	log.Infof("Server listening on %s", serverAddr)
-	if err := http.ListenAndServe(serverAddr, nil); err != nil {
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: http.DefaultServeMux,
+	}
+	server.RegisterOnShutdown(restHandler.Shutdown)
+
+	// Watch the config and avoid list files, and reload both on SIGHUP
+	reload := &reloader{
+		args:             os.Args[1:],
+		cfg:              cfg,
+		blockchainClient: blockchainClient,
+		avoidListSvc:     avoidListSvc,
+		sessions:         sessions,
+	}
+	watcher := config.NewWatcher(cfg.ReloadInterval)
+	watcher.Watch(reload.reloadConfig, reload.configFile, func() string { return config.DotEnvFile })
+	watcher.Watch(reload.reloadAvoidList, reload.avoidListPath)
+	watcher.Watch(reload.reloadOverrides, avoidListSvc.OverridesPath)
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go watcher.Run(background)
+
+	// Keep the avoid list fresh from Dune
+	go avoidListSvc.Run(background)
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		log.Infof("Server listening on %s", server.Addr)
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	// Wait for a shutdown signal
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serverErrors:
 		log.Fatalf("Server failed: %v", err)
+	case <-signals.Done():
+		stop()
+	}
+
+	log.Infof("Shutting down, waiting up to %s for running guesses...", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Tell players first and stop starting queued guesses, so none begins while
+	// the connections drain, then stop accepting connections
+	wsHandler.GoToSleep()
+	jobManager.StopAccepting()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Warnf("HTTP server did not shut down cleanly: %v", err)
 	}
+
+	// Let running guesses deliver their results before the connections close
+	if err := jobManager.Shutdown(shutdownCtx); err != nil {
+		log.Warnf("%v", err)
+	}
+	wsHandler.CloseConnections()
+
+	// Flush persistent caches
+	if saved, err := walletGuesser.SaveResultCache(cfg.ResultCachePath); err != nil {
+		log.Errorf("Failed to save result cache: %v", err)
+	} else {
+		log.Infof("Saved %d cached guess results to %s", saved, cfg.ResultCachePath)
+	}
+	if saved, err := blockchainClient.SaveHolderCache(cfg.HolderCachePath); err != nil {
+		log.Errorf("Failed to save holder cache: %v", err)
+	} else {
+		log.Infof("Saved cached holders of %d tokens to %s", saved, cfg.HolderCachePath)
+	}
+
+	log.Info("The Jinn is asleep. Goodbye!")
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"wallet-guesser/internal/avoidlist"
+	"wallet-guesser/internal/blockchain"
+	"wallet-guesser/internal/config"
+	"wallet-guesser/internal/game"
+	"wallet-guesser/internal/logging"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reloader applies configuration and avoid list changes to the running server.
+// Every change is a swap behind the owning component's lock, so running guesses
+// carry on with whichever value they read last.
+type reloader struct {
+	args             []string
+	cfg              *config.Config
+	mutex            sync.Mutex
+	blockchainClient *blockchain.Client
+	avoidListSvc     *avoidlist.Service
+	sessions         *game.SessionStore
+}
+
+// configFile returns the config file being watched, if any
+func (r *reloader) configFile() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.cfg.ConfigFile
+}
+
+// avoidListPath returns the avoid list file being watched
+func (r *reloader) avoidListPath() string {
+	return r.avoidListSvc.FilePath()
+}
+
+// reloadConfig re-reads the configuration and applies the settings that can
+// change at runtime. An invalid configuration is logged and ignored.
+func (r *reloader) reloadConfig() {
+	next, err := config.Load(r.args)
+	if err != nil {
+		log.Errorf("Keeping current configuration, reload failed: %v", err)
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	changed := r.cfg.Diff(next)
+	if len(changed) == 0 {
+		log.Debug("Configuration reloaded, nothing changed")
+		return
+	}
+
+	var applied, needRestart []string
+	for _, key := range changed {
+		switch key {
+		case "debug", "log_format":
+			if err := logging.Configure(next.LogFormat, next.Debug); err != nil {
+				log.Errorf("Failed to apply log settings: %v", err)
+				continue
+			}
+		case "solana_rpc_endpoint":
+			r.blockchainClient.SetRPCEndpoint(next.SolanaRpcEndpoint)
+		case "confident_threshold", "uncertain_threshold":
+			r.sessions.SetThresholds(game.Thresholds{Confident: next.ConfidentThreshold, Uncertain: next.UncertainThreshold})
+		case "avoid_list_path":
+			r.avoidListSvc.SetFilePath(next.AvoidListPath)
+			if err := r.avoidListSvc.LoadFromFile(); err != nil {
+				log.Errorf("Failed to load avoid list from %s: %v", next.AvoidListPath, err)
+			}
+		default:
+			needRestart = append(needRestart, key)
+			continue
+		}
+		applied = append(applied, key)
+	}
+
+	if len(applied) > 0 {
+		log.Infof("Applied configuration changes: %s", strings.Join(applied, ", "))
+	}
+	if len(needRestart) > 0 {
+		log.Warnf("Configuration changes that take effect after a restart: %s", strings.Join(needRestart, ", "))
+	}
+
+	// Keep comparing against the configuration actually in force
+	for _, key := range needRestart {
+		next.CopySetting(r.cfg, key)
+	}
+	r.cfg = next
+}
+
+// reloadOverrides re-reads the avoid list overrides, keeping the current ones if
+// the file is invalid. A file the server saved itself is already loaded.
+func (r *reloader) reloadOverrides() {
+	if !r.avoidListSvc.OverridesChanged() {
+		log.Debug("Avoid list overrides unchanged since they were last loaded or saved")
+		return
+	}
+	if err := r.avoidListSvc.LoadOverrides(); err != nil {
+		log.Errorf("Keeping current avoid list overrides, reload failed: %v", err)
+	}
+}
+
+// reloadAvoidList re-reads the avoid list file, keeping the current entries if
+// it is invalid. A file the server saved itself, after a refresh or an admin
+// change, is already loaded.
+func (r *reloader) reloadAvoidList() {
+	if _, err := os.Stat(r.avoidListPath()); err != nil {
+		log.Warnf("Keeping current avoid list: %v", err)
+		return
+	}
+	if !r.avoidListSvc.FileChanged() {
+		log.Debug("Avoid list file unchanged since it was last loaded or saved")
+		return
+	}
+	if err := r.avoidListSvc.LoadFromFile(); err != nil {
+		log.Errorf("Keeping current avoid list, reload failed: %v", err)
+	}
+}
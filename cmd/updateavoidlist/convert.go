@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"wallet-guesser/internal/avoidlist"
+)
+
+// convertCommands are the subcommands that move the list in and out of other files
+var convertCommands = map[string]bool{
+	"import": true,
+	"export": true,
+}
+
+// runConvertCommand exports the avoid list to a file or replaces it with one.
+// The format follows the file name: .bin is binary, anything else JSON.
+//
+//	updateavoidlist export avoidlist.json
+//	updateavoidlist import avoidlist.json [-dry-run]
+func runConvertCommand(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	outputFile := flags.String("output", "", "Path to the avoid list file (default: data/avoidlist.bin)")
+	historyDir := flags.String("history", "", "Directory snapshots are kept in (default: next to the avoid list file)")
+	dryRun := flags.Bool("dry-run", false, "Show what an import would change without saving it")
+	diffLimit := flags.Int("diff-limit", defaultDiffLimit, "Number of added, removed and changed entries listed per type")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: updateavoidlist %s <file> [flags]\n", command)
+		flags.PrintDefaults()
+	}
+
+	// Accept flags both before and after the file
+	_ = flags.Parse(args)
+	var path string
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+		_ = flags.Parse(flags.Args()[1:])
+	}
+	if path == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	service := avoidlist.NewService("", *outputFile,
+		avoidlist.WithHistoryDir(*historyDir),
+		avoidlist.WithDryRun(*dryRun),
+	)
+	if err := service.LoadFromFile(); err != nil {
+		log.Fatalf("Failed to load the avoid list: %v", err)
+	}
+
+	switch command {
+	case "export":
+		exported, err := service.Export(path)
+		if err != nil {
+			log.Fatalf("Failed to export the avoid list: %v", err)
+		}
+		fmt.Printf("Exported %d entries to %s\n", exported, path)
+
+	case "import":
+		before := service.Entries()
+		if _, err := service.Snapshot(); err != nil {
+			log.Fatalf("Failed to snapshot the avoid list: %v", err)
+		}
+		imported, dropped, err := service.Import(path)
+		if err != nil {
+			log.Fatalf("Failed to import %s: %v", path, err)
+		}
+		fmt.Printf("Imported %d entries from %s into %s", imported, path, service.FilePath())
+		if dropped > 0 {
+			fmt.Printf(", dropped %d invalid entries", dropped)
+		}
+		fmt.Println()
+		printDiff(avoidlist.DiffEntries(before, service.Entries()), *diffLimit)
+		if *dryRun {
+			fmt.Printf("Dry run, nothing was saved\n")
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"wallet-guesser/internal/avoidlist"
+	"wallet-guesser/internal/domain"
+)
+
+// defaultDiffLimit is how many entries of each kind of change are listed per type
+const defaultDiffLimit = 20
+
+// historyCommands are the subcommands that inspect and restore snapshots
+var historyCommands = map[string]bool{
+	"history":  true,
+	"rollback": true,
+}
+
+// runHistoryCommand lists the avoid list snapshots or restores one of them
+//
+//	updateavoidlist history
+//	updateavoidlist rollback [snapshot] [-dry-run]
+func runHistoryCommand(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	outputFile := flags.String("output", "", "Path to the avoid list file (default: data/avoidlist.bin)")
+	historyDir := flags.String("history", "", "Directory the snapshots are kept in (default: next to the avoid list file)")
+	dryRun := flags.Bool("dry-run", false, "Show what a rollback would change without saving it")
+	diffLimit := flags.Int("diff-limit", defaultDiffLimit, "Number of added, removed and changed entries listed per type")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: updateavoidlist %s", command)
+		if command == "rollback" {
+			fmt.Fprint(flags.Output(), " [snapshot, default: the latest]")
+		}
+		fmt.Fprintln(flags.Output(), " [flags]")
+		flags.PrintDefaults()
+	}
+
+	// Accept flags both before and after the snapshot name
+	_ = flags.Parse(args)
+	var name string
+	if flags.NArg() > 0 {
+		name = flags.Arg(0)
+		_ = flags.Parse(flags.Args()[1:])
+	}
+
+	service := avoidlist.NewService("", *outputFile,
+		avoidlist.WithHistoryDir(*historyDir),
+		avoidlist.WithDryRun(*dryRun),
+	)
+
+	switch command {
+	case "history":
+		snapshots, err := service.Snapshots()
+		if err != nil {
+			log.Fatalf("Failed to list snapshots: %v", err)
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf("No snapshots in %s\n", service.HistoryDir())
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "SNAPSHOT\tTAKEN\tENTRIES\tLIST UPDATED")
+		for _, snapshot := range snapshots {
+			fmt.Fprintf(table, "%s\t%s\t%d\t%s\n", snapshot.Name, formatTime(snapshot.TakenAt),
+				snapshot.Entries, formatTime(snapshot.LastUpdated))
+		}
+		table.Flush()
+
+	case "rollback":
+		if err := service.LoadFromFile(); err != nil {
+			log.Fatalf("Failed to load the current avoid list: %v", err)
+		}
+		before := service.Entries()
+		restored, err := service.Rollback(name)
+		if err != nil {
+			log.Fatalf("Failed to roll back: %v", err)
+		}
+		fmt.Printf("Rolled back to %s\n", restored)
+		printDiff(avoidlist.DiffEntries(before, service.Entries()), *diffLimit)
+		if *dryRun {
+			fmt.Printf("Dry run, nothing was saved\n")
+		}
+	}
+}
+
+// printDiff prints the added, removed and changed entries, counted by type,
+// listing at most limit entries of each kind per type
+func printDiff(diff avoidlist.Diff, limit int) {
+	if diff.Empty() {
+		fmt.Printf("No changes to the avoid list\n")
+		return
+	}
+
+	fmt.Printf("Changes to the avoid list:\n")
+	for _, entryType := range []string{"t", "w", ""} {
+		added := entriesOfType(diff.Added, entryType)
+		removed := entriesOfType(diff.Removed, entryType)
+		var changed []avoidlist.EntryChange
+		for _, change := range diff.Changed {
+			if change.After.Type == entryType {
+				changed = append(changed, change)
+			}
+		}
+		if len(added) == 0 && len(removed) == 0 && len(changed) == 0 {
+			continue
+		}
+
+		fmt.Printf("  %s: %d added, %d removed, %d changed\n", typeName(entryType), len(added), len(removed), len(changed))
+		printEntries("+", added, limit)
+		printEntries("-", removed, limit)
+		for i, change := range changed {
+			if i == limit {
+				fmt.Printf("    ~ ... and %d more\n", len(changed)-limit)
+				break
+			}
+			fmt.Printf("    ~ %s %s -> %s\n", change.After.Key(), change.Before.CategoryOrDefault(), change.After.CategoryOrDefault())
+		}
+	}
+}
+
+// printEntries lists up to limit entries after a marker
+func printEntries(marker string, entries []domain.AvoidListEntry, limit int) {
+	for i, entry := range entries {
+		if i == limit {
+			fmt.Printf("    %s ... and %d more\n", marker, len(entries)-limit)
+			return
+		}
+		fmt.Printf("    %s %s (%s)\n", marker, entry.Key(), entry.CategoryOrDefault())
+	}
+}
+
+// entriesOfType returns the entries of one type
+func entriesOfType(entries []domain.AvoidListEntry, entryType string) []domain.AvoidListEntry {
+	var matching []domain.AvoidListEntry
+	for _, entry := range entries {
+		if entry.Type == entryType {
+			matching = append(matching, entry)
+		}
+	}
+	return matching
+}
+
+// typeName is the plural name of an entry type in the diff
+func typeName(entryType string) string {
+	switch entryType {
+	case "t":
+		return "Tokens"
+	case "w":
+		return "Wallets"
+	default:
+		return "Untyped"
+	}
+}
+
+// formatTime prints a time to the second, or "-" when it is unknown
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -1,30 +1,80 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
 
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 	"wallet-guesser/internal/avoidlist"
+	"wallet-guesser/internal/blockchain"
+	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/logging"
 )
 
 func main() {
+	// Manage the overrides file
+	if len(os.Args) > 1 && overrideCommands[os.Args[1]] {
+		if err := godotenv.Load(); err != nil {
+			log.Debugf("Could not load environment file: %v", err)
+		}
+		runOverrideCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	// Inspect or restore snapshots
+	if len(os.Args) > 1 && historyCommands[os.Args[1]] {
+		runHistoryCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	// Import or export the list in another format
+	if len(os.Args) > 1 && convertCommands[os.Args[1]] {
+		runConvertCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// Parse command line arguments
 	var outputFile string
 	var verbose bool
+	var force, dryRun bool
+	var historyDir string
+	var historyLimit, diffLimit int
+	var queryID int
+	var execute bool
+	var fromRPC bool
+	var holderCachePath string
+	var rpcEndpoint string
+	var holderThreshold, tokenThreshold, minAppearances, concurrency int
 
-	flag.StringVar(&outputFile, "output", "", "Path to output file (default: data/avoidlist.json)")
+	flag.StringVar(&outputFile, "output", "", "Path to output file, binary when it ends in .bin and JSON otherwise (default: data/avoidlist.bin)")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.BoolVar(&force, "force", false, "Refresh from Dune even if the list was updated within the past day")
+	flag.BoolVar(&dryRun, "dry-run", false, "Show what would change without saving anything")
+	flag.IntVar(&queryID, "query-id", 0, "Dune query that returns the avoid list (default: DUNE_QUERY_ID or the public avoid list query)")
+	flag.BoolVar(&execute, "execute", false, "Run the Dune query afresh and wait for it instead of reading its latest results (uses Dune credits)")
+	flag.StringVar(&historyDir, "history", "", "Directory snapshots are kept in (default: next to the output file)")
+	flag.IntVar(&historyLimit, "keep", avoidlist.DefaultHistoryLimit, "Number of snapshots to keep, 0 for all")
+	flag.IntVar(&diffLimit, "diff-limit", defaultDiffLimit, "Number of added, removed and changed entries listed per type")
+	flag.BoolVar(&fromRPC, "rpc", false, "Build the list from on-chain data, merged with Dune when DUNE_API_KEY is set")
+	flag.StringVar(&holderCachePath, "holder-cache", "", "Token holder cache saved by the server, listing the tokens to check (default: HOLDER_CACHE_PATH or data/holders.json)")
+	flag.StringVar(&rpcEndpoint, "rpc-endpoint", "", "Solana RPC endpoint (default: SOLANA_RPC_ENDPOINT)")
+	flag.IntVar(&holderThreshold, "holder-threshold", avoidlist.DefaultHolderThreshold, "Avoid tokens with more holders than this")
+	flag.IntVar(&tokenThreshold, "token-threshold", avoidlist.DefaultTokenThreshold, "Avoid wallets holding more tokens than this")
+	flag.IntVar(&minAppearances, "min-appearances", avoidlist.DefaultMinAppearances, "Only count the tokens of wallets in at least this many cached holder lists")
+	flag.IntVar(&concurrency, "concurrency", avoidlist.DefaultBuildConcurrency, "Number of RPC requests to run at once")
 	flag.Parse()
 
 	// Configure logging
-	log.SetOutput(os.Stdout)
-	if verbose {
-		log.SetLevel(log.DebugLevel)
-	} else {
-		log.SetLevel(log.InfoLevel)
+	if err := logging.Configure(logging.FormatText, verbose); err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
 	}
 
 	// Load environment variables
@@ -32,31 +82,155 @@ func main() {
 		log.WithError(err).Warnf("Could not load environment file")
 	}
 
-	// Get API key from environment
+	if queryID == 0 {
+		queryID = defaultQueryID()
+	}
+
+	// Get API key from environment; only the RPC mode can do without it
 	apiKey := os.Getenv("DUNE_API_KEY")
-	if apiKey == "" {
+	if apiKey == "" && !fromRPC {
 		log.Fatal("DUNE_API_KEY environment variable not set")
 	}
+	logging.RegisterSecret(apiKey)
 
 	// Create avoid list service
-	service := avoidlist.NewService(apiKey, outputFile)
+	service := avoidlist.NewService(apiKey, outputFile,
+		avoidlist.WithDuneQueryID(queryID),
+		avoidlist.WithDuneExecution(execute),
+		avoidlist.WithHistoryDir(historyDir),
+		avoidlist.WithHistoryLimit(historyLimit),
+		avoidlist.WithDryRun(dryRun),
+	)
 
 	// Try to load existing data first
 	if err := service.LoadFromFile(); err != nil {
 		log.Warnf("Could not load existing avoid list: %v", err)
 	}
+	before := service.Entries()
+
+	// Keep the current file so the update can be rolled back
+	if _, err := service.Snapshot(); err != nil {
+		log.Fatalf("Failed to snapshot the avoid list: %v", err)
+	}
 
 	// Update the avoid list
-	log.Info("Updating avoid list...")
-	if err := service.UpdateAvoidList(); err != nil {
-		log.Fatalf("Failed to update avoid list: %v", err)
+	if apiKey != "" {
+		log.Info("Updating avoid list...")
+		var err error
+		if force {
+			err = service.ForceUpdateAvoidList()
+		} else {
+			err = service.UpdateAvoidList()
+		}
+		if errors.Is(err, avoidlist.ErrUpdatedRecently) {
+			if !fromRPC {
+				fmt.Printf("Not updating: %v. Use -force to update anyway.\n", err)
+				return
+			}
+			log.Infof("Not refreshing from Dune: %v", err)
+		} else if err != nil {
+			if !fromRPC {
+				log.Fatalf("Failed to update avoid list: %v", err)
+			}
+			log.Warnf("Failed to update avoid list from Dune, merging with the existing list: %v", err)
+		}
 	}
 
-	// Print stats
+	if fromRPC {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		builder := avoidlist.NewBuilder(newRPCClient(rpcEndpoint),
+			avoidlist.WithHolderThreshold(holderThreshold),
+			avoidlist.WithTokenThreshold(tokenThreshold),
+			avoidlist.WithMinAppearances(minAppearances),
+			avoidlist.WithBuildConcurrency(concurrency),
+		)
+		if err := buildFromRPC(ctx, service, builder, holderCachePath); err != nil {
+			log.Fatalf("Failed to build avoid list from RPC: %v", err)
+		}
+	}
+
+	// Print what changed and the stats
+	printDiff(avoidlist.DiffEntries(before, service.Entries()), diffLimit)
 	stats := service.GetAvoidListStats()
-	fmt.Printf("Avoid list updated successfully!\n")
+	if dryRun {
+		fmt.Printf("Dry run, nothing was saved\n")
+	} else {
+		fmt.Printf("Avoid list updated successfully!\n")
+	}
 	fmt.Printf("  Total entries: %d\n", stats["totalEntries"])
 	fmt.Printf("  Token entries: %d\n", stats["tokenCount"])
 	fmt.Printf("  Wallet entries: %d\n", stats["walletCount"])
 	fmt.Printf("  Last updated: %s\n", stats["lastUpdated"])
+	if categories, ok := stats["categories"].(map[domain.AvoidCategory]int); ok && len(categories) > 0 {
+		fmt.Printf("  By category:\n")
+		for _, category := range sortedCategories(categories) {
+			fmt.Printf("    %s: %d\n", category, categories[category])
+		}
+	}
+}
+
+// defaultQueryID is the Dune query set in DUNE_QUERY_ID, or the public avoid list query
+func defaultQueryID() int {
+	if queryID, err := strconv.Atoi(os.Getenv("DUNE_QUERY_ID")); err == nil && queryID > 0 {
+		return queryID
+	}
+	return avoidlist.DefaultDuneQueryID
+}
+
+// sortedCategories returns the categories in alphabetical order
+func sortedCategories(categories map[domain.AvoidCategory]int) []domain.AvoidCategory {
+	sorted := make([]domain.AvoidCategory, 0, len(categories))
+	for category := range categories {
+		sorted = append(sorted, category)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// newRPCClient creates a blockchain client for counting. It has no avoid list,
+// so nothing already on the list is hidden from the counts.
+func newRPCClient(rpcEndpoint string) *blockchain.Client {
+	if rpcEndpoint == "" {
+		rpcEndpoint = os.Getenv("SOLANA_RPC_ENDPOINT")
+	}
+	if rpcEndpoint == "" {
+		log.Fatal("SOLANA_RPC_ENDPOINT environment variable not set and no -rpc-endpoint given")
+	}
+	logging.RegisterSecret(rpcEndpoint)
+	return blockchain.NewClient(rpcEndpoint, nil)
+}
+
+// buildFromRPC counts the holders and holdings of the addresses in the holder
+// cache and merges those over the thresholds into the avoid list
+func buildFromRPC(ctx context.Context, service *avoidlist.Service, builder *avoidlist.Builder, holderCachePath string) error {
+	if holderCachePath == "" {
+		holderCachePath = os.Getenv("HOLDER_CACHE_PATH")
+	}
+	if holderCachePath == "" {
+		holderCachePath = "data/holders.json"
+	}
+
+	holders, err := blockchain.ReadHolderCache(holderCachePath)
+	if err != nil {
+		return err
+	}
+	if len(holders) == 0 {
+		return fmt.Errorf("no tokens in %s, run the server to collect some first", holderCachePath)
+	}
+
+	result, err := builder.Build(ctx, holders)
+	if err != nil {
+		return err
+	}
+
+	changed, err := service.MergeEntries(result.Entries)
+	if err != nil {
+		return fmt.Errorf("failed to save avoid list: %w", err)
+	}
+
+	fmt.Printf("Checked %d tokens and %d wallets on chain (%d failed)\n", result.TokensChecked, result.WalletsChecked, result.Failures)
+	fmt.Printf("  Over the thresholds: %d, new or changed: %d\n", len(result.Entries), changed)
+	return nil
 }
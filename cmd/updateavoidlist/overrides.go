@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"wallet-guesser/internal/avoidlist"
+	"wallet-guesser/internal/domain"
+)
+
+// overrideCommands are the subcommands that manage the overrides file
+var overrideCommands = map[string]bool{
+	"deny":      true,
+	"allow":     true,
+	"unset":     true,
+	"overrides": true,
+}
+
+// runOverrideCommand edits or lists the manual overrides. A running server
+// picks up the changes on its next reload.
+//
+//	updateavoidlist deny <address> -reason "exchange hot wallet" [-type w] [-author name]
+//	updateavoidlist allow <address> -reason "wrongly flagged by Dune" [-author name]
+//	updateavoidlist unset <address>
+//	updateavoidlist overrides
+func runOverrideCommand(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	overridesPath := flags.String("overrides", "", "Path to the overrides file (default: AVOID_LIST_OVERRIDES_PATH or data/avoidlist_overrides.json)")
+	reason := flags.String("reason", "", "Why the address is overridden (required for deny and allow)")
+	author := flags.String("author", currentUser(), "Who made the decision")
+	entryType := flags.String("type", "", "\"t\" for a token, \"w\" for a wallet")
+	category := flags.String("category", string(domain.CategoryManual), "Why a denied address is avoided: exchange, market_maker, bot, program, burn, lp_vault, stablecoin, manual or a new snake_case category")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: updateavoidlist %s", command)
+		if command != "overrides" {
+			fmt.Fprint(flags.Output(), " <address or prefix>")
+		}
+		fmt.Fprintln(flags.Output(), " [flags]")
+		flags.PrintDefaults()
+	}
+
+	// Accept flags both before and after the address
+	_ = flags.Parse(args)
+	var address string
+	if flags.NArg() > 0 {
+		address = flags.Arg(0)
+		_ = flags.Parse(flags.Args()[1:])
+	}
+	if command != "overrides" && address == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *overridesPath == "" {
+		*overridesPath = os.Getenv("AVOID_LIST_OVERRIDES_PATH")
+	}
+	service := avoidlist.NewService("", "", avoidlist.WithOverridesPath(*overridesPath))
+	if err := service.LoadOverrides(); err != nil {
+		log.Fatalf("Failed to load overrides: %v", err)
+	}
+
+	switch command {
+	case "deny", "allow":
+		override := domain.AvoidListOverride{
+			Address: address,
+			Action:  command,
+			Type:    *entryType,
+			Reason:  *reason,
+			Author:  *author,
+		}
+		if command == "deny" {
+			override.Category = domain.AvoidCategory(*category)
+		}
+		if err := service.SetOverride(override); err != nil {
+			log.Fatalf("Failed to %s %s: %v", command, address, err)
+		}
+		fmt.Printf("%s: %s (%s)\n", command, address, *reason)
+
+	case "unset":
+		removed, err := service.RemoveOverride(address)
+		if err != nil {
+			log.Fatalf("Failed to remove override: %v", err)
+		}
+		if !removed {
+			log.Fatalf("No override for %s", address)
+		}
+		fmt.Printf("Removed override for %s\n", address)
+
+	case "overrides":
+		overrides := service.Overrides()
+		if len(overrides) == 0 {
+			fmt.Printf("No overrides in %s\n", service.OverridesPath())
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "ACTION\tADDRESS\tTYPE\tCATEGORY\tAUTHOR\tADDED\tREASON")
+		for _, override := range overrides {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", override.Action, override.Key(), override.Type,
+				override.CategoryOrDefault(), override.Author, override.CreatedAt.Format("2006-01-02"), override.Reason)
+		}
+		table.Flush()
+	}
+}
+
+// currentUser is the default author of an override
+func currentUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
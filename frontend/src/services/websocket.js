@@ -6,6 +6,7 @@ export function connectWebSocket({ onMessage, onConnect, onDisconnect }) {
     let reconnectAttempts = 0;
     const maxReconnectAttempts = 5;
     let messageQueue = [];
+    let sessionId = null; // Issued by the server so a dropped connection can resume its game
 
     // Create WebSocket connection
     function connect() {
@@ -23,6 +24,11 @@ export function connectWebSocket({ onMessage, onConnect, onDisconnect }) {
             console.log('WebSocket connection established');
             reconnectAttempts = 0; // Reset reconnect attempts on successful connection
 
+            // Reattach to the game we were playing before the connection dropped
+            if (sessionId) {
+                ws.send(JSON.stringify({ v: 1, type: 'RESUME_SESSION', payload: { sessionId } }));
+            }
+
             // Process any queued messages
             if (messageQueue.length > 0) {
                 console.log(`Processing ${messageQueue.length} queued messages`);
@@ -41,6 +47,10 @@ export function connectWebSocket({ onMessage, onConnect, onDisconnect }) {
         ws.addEventListener('message', (event) => {
             try {
                 const data = JSON.parse(event.data);
+                if (data.type === 'GAME_STATE' && data.payload && data.payload.sessionId) {
+                    // Track the latest session; a successful resume re-sends the original id
+                    sessionId = data.payload.sessionId;
+                }
                 if (onMessage) onMessage(data);
             } catch (error) {
                 console.error('Error parsing WebSocket message:', error);
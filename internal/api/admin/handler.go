@@ -0,0 +1,231 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"wallet-guesser/internal/api/response"
+	"wallet-guesser/internal/avoidlist"
+	"wallet-guesser/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler serves the authenticated admin API for caches and the avoid list
+type Handler struct {
+	token            string
+	walletGuesserSvc domain.WalletGuesserService
+	avoidListSvc     domain.AvoidListService
+}
+
+// NewHandler creates a new admin handler. An empty token disables the admin API.
+func NewHandler(token string, walletGuesserSvc domain.WalletGuesserService, avoidListSvc domain.AvoidListService) *Handler {
+	return &Handler{
+		token:            token,
+		walletGuesserSvc: walletGuesserSvc,
+		avoidListSvc:     avoidListSvc,
+	}
+}
+
+// Register adds the admin routes to a mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/admin/cache", h.requireToken(h.handleCacheStats))
+	mux.HandleFunc("DELETE /api/admin/cache", h.requireToken(h.handlePurgeCache))
+	mux.HandleFunc("DELETE /api/admin/cache/handles/{handle}", h.requireToken(h.handlePurgeHandle))
+	mux.HandleFunc("DELETE /api/admin/cache/mints/{mint}", h.requireToken(h.handlePurgeMint))
+
+	mux.HandleFunc("GET /api/admin/avoidlist", h.requireToken(h.handleAvoidListStats))
+	mux.HandleFunc("POST /api/admin/avoidlist/refresh", h.requireToken(h.handleAvoidListRefresh))
+	mux.HandleFunc("GET /api/admin/avoidlist/entries/{address}", h.requireToken(h.handleGetEntry))
+	mux.HandleFunc("PUT /api/admin/avoidlist/entries/{prefix}", h.requireToken(h.handlePutEntry))
+	mux.HandleFunc("DELETE /api/admin/avoidlist/entries/{prefix}", h.requireToken(h.handleDeleteEntry))
+}
+
+// requireToken rejects requests without the admin bearer token
+func (h *Handler) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			response.Error(w, http.StatusNotFound, "admin API is disabled")
+			return
+		}
+
+		provided, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			response.Error(w, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+
+		log.Infof("Admin request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		next(w, r)
+	}
+}
+
+// handleCacheStats returns cache statistics
+func (h *Handler) handleCacheStats(w http.ResponseWriter, _ *http.Request) {
+	response.JSON(w, http.StatusOK, h.walletGuesserSvc.CacheStats())
+}
+
+// handlePurgeCache clears every cache
+func (h *Handler) handlePurgeCache(w http.ResponseWriter, _ *http.Request) {
+	h.walletGuesserSvc.ClearCache()
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"purged": true,
+		"cache":  h.walletGuesserSvc.CacheStats(),
+	})
+}
+
+// handlePurgeHandle clears the cached result for one Twitter handle
+func (h *Handler) handlePurgeHandle(w http.ResponseWriter, r *http.Request) {
+	handle := r.PathValue("handle")
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"handle": handle,
+		"purged": h.walletGuesserSvc.ClearHandleCache(handle),
+	})
+}
+
+// handlePurgeMint clears cached data for one token mint
+func (h *Handler) handlePurgeMint(w http.ResponseWriter, r *http.Request) {
+	mint := r.PathValue("mint")
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"mint":   mint,
+		"purged": h.walletGuesserSvc.ClearTokenCache(mint),
+	})
+}
+
+// handleAvoidListStats returns avoid list statistics
+func (h *Handler) handleAvoidListStats(w http.ResponseWriter, _ *http.Request) {
+	response.JSON(w, http.StatusOK, h.avoidListSvc.GetAvoidListStats())
+}
+
+// handleAvoidListRefresh fetches the avoid list from Dune. A list updated within
+// the past day is left alone with a 409 unless ?force=true is passed.
+func (h *Handler) handleAvoidListRefresh(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if r.URL.Query().Get("force") == "true" {
+		err = h.avoidListSvc.ForceUpdateAvoidList()
+	} else {
+		err = h.avoidListSvc.UpdateAvoidList()
+	}
+	if errors.Is(err, avoidlist.ErrUpdatedRecently) {
+		response.Error(w, http.StatusConflict, fmt.Sprintf("%v, pass ?force=true to refresh anyway", err))
+		return
+	}
+	if err != nil {
+		log.Errorf("Admin avoid list refresh failed: %v", err)
+		response.Error(w, http.StatusBadGateway, fmt.Sprintf("avoid list refresh failed: %v", err))
+		return
+	}
+
+	response.JSON(w, http.StatusOK, h.avoidListSvc.GetAvoidListStats())
+}
+
+// handleGetEntry returns the avoid list entry matching an address
+func (h *Handler) handleGetEntry(w http.ResponseWriter, r *http.Request) {
+	address := r.PathValue("address")
+	entry, found := h.avoidListSvc.GetEntry(address)
+	override, overridden := h.avoidListSvc.GetOverride(address)
+	if !found && !overridden {
+		response.Error(w, http.StatusNotFound, "address is not on the avoid list")
+		return
+	}
+
+	body := map[string]interface{}{}
+	if found {
+		body["entry"] = entry
+	}
+	if overridden {
+		body["override"] = override
+	}
+	avoided, reason := h.avoidListSvc.ShouldAvoid(address)
+	body["avoided"] = avoided
+	body["reason"] = reason
+	response.JSON(w, http.StatusOK, body)
+}
+
+// handlePutEntry adds or replaces a deny override for an address or prefix.
+// Overrides are kept apart from the entries Dune refreshes replace, so an
+// entry added or recategorised here survives the next scheduled refresh.
+func (h *Handler) handlePutEntry(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Type     string               `json:"type"`
+		Category domain.AvoidCategory `json:"category"`
+		Reason   string               `json:"reason"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if body.Reason == "" {
+		body.Reason = "added through the admin API"
+	}
+
+	key := r.PathValue("prefix")
+	override := domain.AvoidListOverride{
+		Prefix:   key,
+		Action:   domain.OverrideDeny,
+		Type:     body.Type,
+		Category: body.Category,
+		Reason:   body.Reason,
+		Author:   "admin API",
+	}
+	if err := h.avoidListSvc.SetOverride(override); err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stored, _ := h.avoidListSvc.GetOverride(key)
+	response.JSON(w, http.StatusOK, stored)
+}
+
+// handleDeleteEntry takes an address or prefix off the avoid list. A deny
+// override stored under it is removed, and an entry the list holds under it is
+// answered with an allow override: the next refresh would bring back a deleted
+// entry, but it leaves overrides alone.
+func (h *Handler) handleDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("prefix")
+	if len(key) < avoidlist.MinPrefixLength {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("prefix must be at least %d characters", avoidlist.MinPrefixLength))
+		return
+	}
+
+	override, overridden := h.avoidListSvc.GetOverride(key)
+	overridden = overridden && override.Key() == key
+
+	removed := false
+	if overridden && override.Action == domain.OverrideDeny {
+		if _, err := h.avoidListSvc.RemoveOverride(key); err != nil {
+			response.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		overridden = false
+		removed = true
+	}
+
+	if entry, found := h.avoidListSvc.GetEntry(key); found && entry.Key() == key && !overridden {
+		allow := domain.AvoidListOverride{
+			Prefix:   key,
+			Action:   domain.OverrideAllow,
+			Type:     entry.Type,
+			Category: entry.Category,
+			Reason:   "removed through the admin API",
+			Author:   "admin API",
+		}
+		if err := h.avoidListSvc.SetOverride(allow); err != nil {
+			response.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		removed = true
+	}
+
+	if !removed {
+		response.Error(w, http.StatusNotFound, "prefix is not on the avoid list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,195 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"wallet-guesser/internal/api/response"
+	"wallet-guesser/internal/buildinfo"
+	"wallet-guesser/internal/domain"
+)
+
+const (
+	// probeTimeout bounds each dependency probe
+	probeTimeout = 5 * time.Second
+	// maxAvoidListAge is how old the avoid list may get before readiness warns about it
+	maxAvoidListAge = 7 * 24 * time.Hour
+)
+
+// Status is the outcome of a single check
+type Status string
+
+// Check statuses. Only a failing check makes the server not ready.
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Check is the result of probing one dependency
+type Check struct {
+	Status    Status                 `json:"status"`
+	Message   string                 `json:"message,omitempty"`
+	LatencyMs int64                  `json:"latencyMs,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// RPCProber probes the Solana RPC endpoint
+type RPCProber interface {
+	GetHealth(ctx context.Context) error
+	GetSlot(ctx context.Context) (uint64, error)
+}
+
+// Handler serves the liveness and readiness endpoints
+type Handler struct {
+	rpc          RPCProber
+	avoidListSvc domain.AvoidListService
+	apifyToken   string
+}
+
+// NewHandler creates a new health handler
+func NewHandler(rpc RPCProber, avoidListSvc domain.AvoidListService, apifyToken string) *Handler {
+	return &Handler{
+		rpc:          rpc,
+		avoidListSvc: avoidListSvc,
+		apifyToken:   apifyToken,
+	}
+}
+
+// Register adds the health routes to a mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.handleLiveness)
+	mux.HandleFunc("GET /readyz", h.handleReadiness)
+	mux.HandleFunc("GET /api/status", h.handleLiveness)
+}
+
+// handleLiveness reports that the process is up, along with its build
+func (h *Handler) handleLiveness(w http.ResponseWriter, _ *http.Request) {
+	info := buildinfo.Get()
+	response.JSON(w, http.StatusOK, map[string]interface{}{
+		"status":  StatusOK,
+		"version": info.Version,
+		"build":   info,
+	})
+}
+
+// handleReadiness probes every dependency and reports whether guesses can be served
+func (h *Handler) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+	defer cancel()
+
+	checks := make(map[string]Check)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	run := func(name string, probe func(context.Context) Check) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check := probe(ctx)
+			mutex.Lock()
+			checks[name] = check
+			mutex.Unlock()
+		}()
+	}
+
+	run("rpc", h.checkRPC)
+	run("avoidList", h.checkAvoidList)
+	run("apify", h.checkApify)
+	wg.Wait()
+
+	overall := StatusOK
+	for _, check := range checks {
+		if check.Status == StatusFail {
+			overall = StatusFail
+			break
+		}
+		if check.Status == StatusWarn {
+			overall = StatusWarn
+		}
+	}
+
+	status := http.StatusOK
+	if overall == StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+
+	response.JSON(w, status, map[string]interface{}{
+		"status": overall,
+		"checks": checks,
+		"build":  buildinfo.Get(),
+	})
+}
+
+// checkRPC calls getHealth and getSlot and reports their latency
+func (h *Handler) checkRPC(ctx context.Context) Check {
+	if h.rpc == nil {
+		return Check{Status: StatusFail, Message: "no RPC client configured"}
+	}
+
+	start := time.Now()
+	if err := h.rpc.GetHealth(ctx); err != nil {
+		return Check{Status: StatusFail, Message: fmt.Sprintf("getHealth failed: %v", err), LatencyMs: time.Since(start).Milliseconds()}
+	}
+	healthLatency := time.Since(start)
+
+	start = time.Now()
+	slot, err := h.rpc.GetSlot(ctx)
+	slotLatency := time.Since(start)
+	if err != nil {
+		return Check{Status: StatusFail, Message: fmt.Sprintf("getSlot failed: %v", err), LatencyMs: slotLatency.Milliseconds()}
+	}
+
+	return Check{
+		Status:    StatusOK,
+		LatencyMs: (healthLatency + slotLatency).Milliseconds(),
+		Details: map[string]interface{}{
+			"slot":        slot,
+			"getHealthMs": healthLatency.Milliseconds(),
+			"getSlotMs":   slotLatency.Milliseconds(),
+		},
+	}
+}
+
+// checkAvoidList warns when the avoid list is empty or stale
+func (h *Handler) checkAvoidList(_ context.Context) Check {
+	if h.avoidListSvc == nil {
+		return Check{Status: StatusWarn, Message: "no avoid list configured"}
+	}
+
+	stats := h.avoidListSvc.GetAvoidListStats()
+	check := Check{Status: StatusOK, Details: stats}
+
+	if total, _ := stats["totalEntries"].(int); total == 0 {
+		check.Status = StatusWarn
+		check.Message = "avoid list is empty, spammy tokens and wallets will not be filtered"
+		return check
+	}
+
+	lastUpdated, err := time.Parse(time.RFC3339, fmt.Sprint(stats["lastUpdated"]))
+	if err != nil || lastUpdated.IsZero() {
+		check.Status = StatusWarn
+		check.Message = "avoid list update time is unknown"
+		return check
+	}
+
+	age := time.Since(lastUpdated)
+	check.Details["age"] = age.Round(time.Minute).String()
+	if age > maxAvoidListAge {
+		check.Status = StatusWarn
+		check.Message = fmt.Sprintf("avoid list is older than %s", maxAvoidListAge)
+	}
+	return check
+}
+
+// checkApify warns when no Apify token is configured. Guesses fail without it,
+// but the avoid list, RPC and WebSocket still work, so the server stays ready.
+func (h *Handler) checkApify(_ context.Context) Check {
+	if h.apifyToken == "" {
+		return Check{Status: StatusWarn, Message: "APIFY_TOKEN is not set, guesses will fail"}
+	}
+	return Check{Status: StatusOK, Details: map[string]interface{}{"tokenPresent": true}}
+}
@@ -0,0 +1,47 @@
+package protocol
+
+import (
+	"encoding/json"
+)
+
+// Decode parses and validates a message received from a client. The returned
+// error is a protocol error suitable for sending back in an ERROR message; the
+// envelope is returned alongside it whenever it could be parsed so the error
+// can be correlated.
+func Decode(data []byte) (*Envelope, *Error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, NewError(ErrorInvalidMessage, "message is not a valid envelope: %v", err)
+	}
+
+	if violations := envelopeSchema.Validate(data); len(violations) > 0 {
+		protocolErr := NewError(ErrorInvalidMessage, "message envelope is invalid")
+		protocolErr.Details = violations
+		return &envelope, protocolErr
+	}
+
+	// Clients written before the envelope was versioned omit the version
+	if envelope.Version == 0 {
+		envelope.Version = Version
+	}
+	if envelope.Version > Version {
+		return &envelope, NewError(ErrorUnsupportedVersion, "protocol version %d is not supported (server speaks %d)", envelope.Version, Version)
+	}
+
+	spec, ok := messageSpecs[envelope.Type]
+	if !ok || spec.Direction != ClientToServer {
+		return &envelope, NewError(ErrorUnknownType, "unknown message type %q", envelope.Type)
+	}
+
+	// Treat a missing payload as an empty object so optional payloads validate
+	if len(envelope.Payload) == 0 || string(envelope.Payload) == "null" {
+		envelope.Payload = json.RawMessage("{}")
+	}
+	if violations := spec.Payload.Validate(envelope.Payload); len(violations) > 0 {
+		protocolErr := NewError(ErrorValidationFailed, "invalid %s payload", envelope.Type)
+		protocolErr.Details = violations
+		return &envelope, protocolErr
+	}
+
+	return &envelope, nil
+}
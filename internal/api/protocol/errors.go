@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorCode is a machine-readable reason sent in ERROR messages
+type ErrorCode string
+
+// Error codes sent to clients
+const (
+	ErrorInvalidMessage     ErrorCode = "INVALID_MESSAGE"
+	ErrorUnsupportedVersion ErrorCode = "UNSUPPORTED_VERSION"
+	ErrorUnknownType        ErrorCode = "UNKNOWN_TYPE"
+	ErrorValidationFailed   ErrorCode = "VALIDATION_FAILED"
+	ErrorSessionNotFound    ErrorCode = "SESSION_NOT_FOUND"
+	ErrorGuessInProgress    ErrorCode = "GUESS_IN_PROGRESS"
+	ErrorVerificationFailed ErrorCode = "VERIFICATION_FAILED"
+	ErrorShuttingDown       ErrorCode = "SHUTTING_DOWN"
+	ErrorInternal           ErrorCode = "INTERNAL_ERROR"
+)
+
+// errorCodeDescriptions documents each error code in the generated spec
+var errorCodeDescriptions = map[ErrorCode]string{
+	ErrorInvalidMessage:     "The message is not a valid JSON envelope.",
+	ErrorUnsupportedVersion: "The envelope's protocol version is newer than the server supports.",
+	ErrorUnknownType:        "The message type is unknown or may not be sent by clients.",
+	ErrorValidationFailed:   "The payload does not match the message type's schema.",
+	ErrorSessionNotFound:    "The session to resume has expired or never existed.",
+	ErrorGuessInProgress:    "A wallet guess is already running for this session.",
+	ErrorVerificationFailed: "Wallet ownership could not be verified.",
+	ErrorShuttingDown:       "The server is shutting down and not starting new guesses.",
+	ErrorInternal:           "The server failed to process the message.",
+}
+
+// ErrorPayload represents the payload for ERROR messages
+type ErrorPayload struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Details []string  `json:"details,omitempty"`
+}
+
+// Error is an error that should be reported to the client with a specific code
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Details []string
+}
+
+// NewError creates a protocol error
+func NewError(code ErrorCode, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if len(e.Details) == 0 {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s: %s (%s)", e.Code, e.Message, strings.Join(e.Details, "; "))
+}
+
+// Payload converts the error into an ERROR message payload
+func (e *Error) Payload() ErrorPayload {
+	return ErrorPayload{Code: e.Code, Message: e.Message, Details: e.Details}
+}
@@ -0,0 +1,139 @@
+// Package protocol defines the messages exchanged between the game frontend and
+// the server over the WebSocket, their JSON Schemas and the error codes sent back
+// to clients.
+package protocol
+
+//go:generate go run ../../../cmd/protocolspec -output ../../../frontend/src/services/protocol.json
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"wallet-guesser/internal/domain"
+)
+
+// Version is the protocol version spoken by this server
+const Version = 1
+
+// MessageType identifies the kind of message carried by an envelope
+type MessageType string
+
+// Messages sent by the client
+const (
+	TypeStartGame           MessageType = "START_GAME"
+	TypeUserInput           MessageType = "USER_INPUT"
+	TypeRequestVerification MessageType = "REQUEST_VERIFICATION"
+	TypeSubmitSignature     MessageType = "SUBMIT_SIGNATURE"
+	TypeResumeSession       MessageType = "RESUME_SESSION"
+)
+
+// Messages sent by the server
+const (
+	TypeGameState             MessageType = "GAME_STATE"
+	TypeJinnState             MessageType = "JINN_STATE"
+	TypeProgressUpdate        MessageType = "PROGRESS_UPDATE"
+	TypeWalletResult          MessageType = "WALLET_RESULT"
+	TypeVerificationChallenge MessageType = "VERIFICATION_CHALLENGE"
+	TypeVerificationResult    MessageType = "VERIFICATION_RESULT"
+	TypeError                 MessageType = "ERROR"
+)
+
+// Envelope wraps every message sent in either direction
+type Envelope struct {
+	Version       int             `json:"v"`
+	ID            string          `json:"id,omitempty"`
+	CorrelationID string          `json:"correlationId,omitempty"`
+	Type          MessageType     `json:"type"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+}
+
+// NewEnvelope builds an outbound message with a fresh id
+func NewEnvelope(messageType MessageType, payload interface{}) (*Envelope, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %w", messageType, err)
+	}
+
+	return &Envelope{
+		Version: Version,
+		ID:      NewMessageID(),
+		Type:    messageType,
+		Payload: payloadBytes,
+	}, nil
+}
+
+// NewMessageID generates a random message identifier
+func NewMessageID() string {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(idBytes)
+}
+
+// UnmarshalPayload decodes the envelope's payload into a typed payload struct
+func (e *Envelope) UnmarshalPayload(target interface{}) error {
+	if len(e.Payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(e.Payload, target); err != nil {
+		return fmt.Errorf("error unmarshaling %s payload: %w", e.Type, err)
+	}
+	return nil
+}
+
+// UserInputPayload represents the payload for USER_INPUT messages
+type UserInputPayload struct {
+	Twitter string `json:"twitter"`
+}
+
+// ResumeSessionPayload represents the payload for RESUME_SESSION messages
+type ResumeSessionPayload struct {
+	SessionID string `json:"sessionId"`
+}
+
+// GameStatePayload represents the payload for GAME_STATE messages
+type GameStatePayload = domain.GameState
+
+// JinnStatePayload represents a Jinn state update message payload
+type JinnStatePayload struct {
+	State   string `json:"state"`
+	Message string `json:"message"`
+}
+
+// ProgressPayload represents a progress update message
+type ProgressPayload struct {
+	Message   string              `json:"message"`
+	Exclusion *domain.AvoidReason `json:"exclusion,omitempty"`
+}
+
+// WalletResultPayload represents the payload for WALLET_RESULT messages
+type WalletResultPayload = domain.WalletGuessResult
+
+// VerificationRequestPayload represents the payload for REQUEST_VERIFICATION messages
+type VerificationRequestPayload struct {
+	Address string `json:"address"`
+}
+
+// VerificationChallengePayload represents the payload for VERIFICATION_CHALLENGE messages
+type VerificationChallengePayload struct {
+	Nonce     string `json:"nonce"`
+	Address   string `json:"address"`
+	Message   string `json:"message"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// SignatureSubmissionPayload represents the payload for SUBMIT_SIGNATURE messages
+type SignatureSubmissionPayload struct {
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"` // base58 or base64 encoded ed25519 signature
+}
+
+// VerificationResultPayload represents the payload for VERIFICATION_RESULT messages
+type VerificationResultPayload struct {
+	Address  string `json:"address"`
+	Verified bool   `json:"verified"`
+	Message  string `json:"message"`
+}
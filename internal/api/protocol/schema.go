@@ -0,0 +1,160 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// Schema is the subset of JSON Schema (draft 2020-12) used to describe message payloads
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// patternCache holds compiled patterns so validation does not recompile them per message
+var patternCache sync.Map // pattern -> *regexp.Regexp
+
+// Validate checks a raw JSON document against the schema and returns every violation
+func (s *Schema) Validate(data json.RawMessage) []string {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = json.RawMessage("null")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("payload is not valid JSON: %v", err)}
+	}
+
+	var violations []string
+	s.validate(value, "payload", &violations)
+	return violations
+}
+
+// validate checks a decoded value, appending violations found at path
+func (s *Schema) validate(value interface{}, path string, violations *[]string) {
+	addf := func(format string, args ...interface{}) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			addf("expected object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, present := object[name]; !present {
+				addf("missing required property %q", name)
+			}
+		}
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propertySchema, known := s.Properties[name]
+			if !known {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					addf("unexpected property %q", name)
+				}
+				continue
+			}
+			propertySchema.validate(object[name], path+"."+name, violations)
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			addf("expected array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			addf("expected string")
+			return
+		}
+		length := utf8.RuneCountInString(str)
+		if s.MinLength != nil && length < *s.MinLength {
+			addf("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			addf("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" && !compilePattern(s.Pattern).MatchString(str) {
+			addf("does not match pattern %s", s.Pattern)
+		}
+		if len(s.Enum) > 0 && !containsString(s.Enum, str) {
+			addf("must be one of %v", s.Enum)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			addf("expected %s", s.Type)
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				addf("expected integer")
+				return
+			}
+		}
+		floatValue, _ := number.Float64()
+		if s.Minimum != nil && floatValue < *s.Minimum {
+			addf("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && floatValue > *s.Maximum {
+			addf("must be <= %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			addf("expected boolean")
+		}
+	}
+}
+
+// compilePattern returns the compiled form of a schema pattern
+func compilePattern(pattern string) *regexp.Regexp {
+	if compiled, ok := patternCache.Load(pattern); ok {
+		return compiled.(*regexp.Regexp)
+	}
+	compiled := regexp.MustCompile(pattern)
+	patternCache.Store(pattern, compiled)
+	return compiled
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package protocol
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"wallet-guesser/internal/domain"
+)
+
+// Direction describes which side of the connection sends a message type
+type Direction string
+
+// Message directions
+const (
+	ClientToServer Direction = "client->server"
+	ServerToClient Direction = "server->client"
+)
+
+// MessageSpec describes one message type in the protocol
+type MessageSpec struct {
+	Direction   Direction `json:"direction"`
+	Description string    `json:"description"`
+	Payload     *Schema   `json:"payload"`
+}
+
+// Spec is the machine-readable protocol description consumed by the frontend
+type Spec struct {
+	Schema     string                      `json:"$schema"`
+	Version    int                         `json:"version"`
+	Envelope   *Schema                     `json:"envelope"`
+	Messages   map[MessageType]MessageSpec `json:"messages"`
+	ErrorCodes map[ErrorCode]string        `json:"errorCodes"`
+}
+
+// Schema helpers keep the message definitions below readable
+func intPtr(v int) *int              { return &v }
+func floatPtr(v float64) *float64    { return &v }
+func boolPtr(v bool) *bool           { return &v }
+func str(description string) *Schema { return &Schema{Type: "string", Description: description} }
+
+func object(required []string, properties map[string]*Schema) *Schema {
+	return &Schema{
+		Type:                 "object",
+		Properties:           properties,
+		Required:             required,
+		AdditionalProperties: boolPtr(false),
+	}
+}
+
+var (
+	solanaAddressSchema = &Schema{
+		Type:        "string",
+		Description: "Base58 encoded Solana address",
+		Pattern:     `^[1-9A-HJ-NP-Za-km-z]{32,44}$`,
+	}
+
+	jinnStateSchema = &Schema{
+		Type: "string",
+		Enum: []string{
+			string(domain.JinnStateIdle),
+			string(domain.JinnStateThinking),
+			string(domain.JinnStateAsking),
+			string(domain.JinnStateConfident),
+			string(domain.JinnStateCorrect),
+			string(domain.JinnStateWrong),
+			string(domain.JinnStateGlitched),
+		},
+	}
+
+	avoidReasonSchema = object([]string{"address", "category", "source", "detail"}, map[string]*Schema{
+		"address": solanaAddressSchema,
+		"kind":    {Type: "string", Enum: []string{"token", "wallet"}},
+		"category": {
+			Type:        "string",
+			Description: "Why the address is avoided: " + knownCategories() + ", or a newer category",
+			Pattern:     `^[a-z][a-z0-9_]*$`,
+		},
+		"source": {Type: "string", Enum: []string{domain.AvoidSourceList, domain.AvoidSourceOverride}},
+		"detail": str("Human readable explanation"),
+	})
+
+	envelopeSchema = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"v":             {Type: "integer", Description: "Protocol version; assumed to be 1 when omitted", Minimum: floatPtr(1)},
+			"id":            {Type: "string", Description: "Sender-chosen message id", MaxLength: intPtr(64)},
+			"correlationId": {Type: "string", Description: "Id of the message this one responds to", MaxLength: intPtr(64)},
+			"type":          {Type: "string", Description: "Message type"},
+			"payload":       {Description: "Message payload, see messages"},
+		},
+		Required:             []string{"type"},
+		AdditionalProperties: boolPtr(false),
+	}
+)
+
+// knownCategories lists the built-in avoid categories for the schema description
+func knownCategories() string {
+	names := make([]string, 0, len(domain.KnownAvoidCategories()))
+	for _, category := range domain.KnownAvoidCategories() {
+		names = append(names, string(category))
+	}
+	return strings.Join(names, ", ")
+}
+
+// messageSpecs defines every message type and its payload schema
+var messageSpecs = map[MessageType]MessageSpec{
+	TypeStartGame: {
+		Direction:   ClientToServer,
+		Description: "Start a new game in the current session.",
+		Payload:     &Schema{Type: "object"},
+	},
+	TypeUserInput: {
+		Direction:   ClientToServer,
+		Description: "Submit the Twitter handle to divine a wallet for. An empty handle makes the Jinn ask for one.",
+		Payload: object([]string{"twitter"}, map[string]*Schema{
+			"twitter": {Type: "string", Description: "Twitter handle, with or without @", MaxLength: intPtr(64)},
+		}),
+	},
+	TypeRequestVerification: {
+		Direction:   ClientToServer,
+		Description: "Ask to prove ownership of one of the guessed addresses.",
+		Payload: object([]string{"address"}, map[string]*Schema{
+			"address": solanaAddressSchema,
+		}),
+	},
+	TypeSubmitSignature: {
+		Direction:   ClientToServer,
+		Description: "Submit the wallet's signature over a VERIFICATION_CHALLENGE message.",
+		Payload: object([]string{"nonce", "signature"}, map[string]*Schema{
+			"nonce":     {Type: "string", Pattern: `^[0-9a-f]{32}$`},
+			"signature": {Type: "string", Description: "Base58 or base64 encoded ed25519 signature", MinLength: intPtr(64), MaxLength: intPtr(128)},
+		}),
+	},
+	TypeResumeSession: {
+		Direction:   ClientToServer,
+		Description: "Reattach this connection to a session issued on an earlier connection.",
+		Payload: object([]string{"sessionId"}, map[string]*Schema{
+			"sessionId": {Type: "string", MinLength: intPtr(1), MaxLength: intPtr(64)},
+		}),
+	},
+	TypeGameState: {
+		Direction:   ServerToClient,
+		Description: "Current game state and the session id to use when resuming.",
+		Payload: object([]string{"jinnState"}, map[string]*Schema{
+			"sessionId": str("Session id for RESUME_SESSION"),
+			"jinnState": jinnStateSchema,
+			"twitter":   str("Twitter handle being guessed"),
+		}),
+	},
+	TypeJinnState: {
+		Direction:   ServerToClient,
+		Description: "The Jinn moved to a new state.",
+		Payload: object([]string{"state", "message"}, map[string]*Schema{
+			"state":   jinnStateSchema,
+			"message": str("What the Jinn says"),
+		}),
+	},
+	TypeProgressUpdate: {
+		Direction:   ServerToClient,
+		Description: "Progress of a running guess. May be coalesced when the client is slow.",
+		Payload: object([]string{"message"}, map[string]*Schema{
+			"message":   str("Progress text"),
+			"exclusion": avoidReasonSchema,
+		}),
+	},
+	TypeWalletResult: {
+		Direction:   ServerToClient,
+		Description: "The result of a wallet guess.",
+		Payload: object([]string{"twitterHandle", "addresses", "sources", "confidence"}, map[string]*Schema{
+			"twitterHandle": str("Twitter handle without @"),
+			"addresses":     {Type: "array", Items: solanaAddressSchema},
+			"sources":       {Type: "array", Items: str("Why the address matched")},
+			"confidence":    {Type: "integer", Minimum: floatPtr(0), Maximum: floatPtr(100)},
+			"excluded":      {Type: "array", Items: avoidReasonSchema},
+		}),
+	},
+	TypeVerificationChallenge: {
+		Direction:   ServerToClient,
+		Description: "A nonce the player must sign with the wallet to prove ownership.",
+		Payload: object([]string{"nonce", "address", "message", "expiresAt"}, map[string]*Schema{
+			"nonce":     str("One-time nonce"),
+			"address":   solanaAddressSchema,
+			"message":   str("Exact text to sign"),
+			"expiresAt": str("RFC 3339 expiry time"),
+		}),
+	},
+	TypeVerificationResult: {
+		Direction:   ServerToClient,
+		Description: "Whether the submitted signature proved wallet ownership.",
+		Payload: object([]string{"address", "verified", "message"}, map[string]*Schema{
+			"address":  str("Verified address, empty when verification failed"),
+			"verified": {Type: "boolean"},
+			"message":  str("Human readable outcome"),
+		}),
+	},
+	TypeError: {
+		Direction:   ServerToClient,
+		Description: "A request could not be processed. correlationId points at the offending message.",
+		Payload: object([]string{"code", "message"}, map[string]*Schema{
+			"code":    {Type: "string", Enum: errorCodeNames()},
+			"message": str("Human readable error"),
+			"details": {Type: "array", Items: str("Individual validation failure")},
+		}),
+	},
+}
+
+// GetSpec returns the full protocol description
+func GetSpec() *Spec {
+	return &Spec{
+		Schema:     "https://json-schema.org/draft/2020-12/schema",
+		Version:    Version,
+		Envelope:   envelopeSchema,
+		Messages:   messageSpecs,
+		ErrorCodes: errorCodeDescriptions,
+	}
+}
+
+// MarshalSpec renders the protocol description as indented JSON
+func MarshalSpec() ([]byte, error) {
+	data, err := json.MarshalIndent(GetSpec(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// errorCodeNames lists every error code in a stable order
+func errorCodeNames() []string {
+	names := make([]string, 0, len(errorCodeDescriptions))
+	for code := range errorCodeDescriptions {
+		names = append(names, string(code))
+	}
+	sort.Strings(names)
+	return names
+}
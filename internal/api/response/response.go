@@ -0,0 +1,32 @@
+// Package response holds helpers shared by the HTTP API handlers.
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// JSON writes a JSON response
+func JSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Errorf("Error writing JSON response: %v", err)
+	}
+}
+
+// Error writes a JSON error response
+func Error(w http.ResponseWriter, status int, message string) {
+	JSON(w, status, map[string]string{"error": message})
+}
+
+// CORS sets the headers that let browser clients on other origins call the API
+// and read the Location of created resources
+func CORS(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, traceparent")
+	w.Header().Set("Access-Control-Expose-Headers", "Location")
+}
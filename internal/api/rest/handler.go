@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"wallet-guesser/internal/api/response"
+	"wallet-guesser/internal/jobs"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	// sseKeepAliveInterval is how often a comment is sent to keep idle event streams open
+	sseKeepAliveInterval = 15 * time.Second
+)
+
+// Handler serves the REST API for scripted wallet guesses
+type Handler struct {
+	jobManager   *jobs.Manager
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// NewHandler creates a new REST handler
+func NewHandler(jobManager *jobs.Manager) *Handler {
+	return &Handler{
+		jobManager: jobManager,
+		shutdown:   make(chan struct{}),
+	}
+}
+
+// Shutdown ends event streams of guesses that are still queued, since they will
+// not run before the server stops. Streams of running guesses continue to their result.
+func (h *Handler) Shutdown() {
+	h.shutdownOnce.Do(func() {
+		close(h.shutdown)
+	})
+}
+
+// Register adds the REST routes to a mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /api/guesses", withCORS(h.handleCreateGuess))
+	mux.HandleFunc("GET /api/guesses/{id}", withCORS(h.handleGetGuess))
+	mux.HandleFunc("GET /api/guesses/{id}/events", withCORS(h.handleGuessEvents))
+
+	// Method-specific routes answer other methods with 405, so preflights need their own
+	for _, path := range []string{"/api/guesses", "/api/guesses/{id}", "/api/guesses/{id}/events"} {
+		mux.HandleFunc("OPTIONS "+path, withCORS(handlePreflight))
+	}
+}
+
+// withCORS adds the CORS headers to every response of a route, errors included
+func withCORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.CORS(w)
+		next(w, r)
+	}
+}
+
+// handlePreflight answers CORS preflight requests
+func handlePreflight(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createGuessRequest is the body of POST /api/guesses
+type createGuessRequest struct {
+	Twitter string `json:"twitter"`
+}
+
+// createGuessResponse is returned when a guess job is accepted
+type createGuessResponse struct {
+	ID       string      `json:"id"`
+	Status   jobs.Status `json:"status"`
+	Position int         `json:"position,omitempty"` // place in the queue, 0 once running
+	Links    struct {
+		Self   string `json:"self"`
+		Events string `json:"events"`
+	} `json:"links"`
+}
+
+// handleCreateGuess starts a wallet guess job
+func (h *Handler) handleCreateGuess(w http.ResponseWriter, r *http.Request) {
+	var request createGuessRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&request); err != nil {
+		response.Error(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	// Scripted callers may pass a W3C traceparent header to join the guess to their own trace
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	job, err := h.jobManager.Submit(ctx, request.Twitter)
+	if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrShuttingDown) {
+		response.Error(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var accepted createGuessResponse
+	accepted.ID = job.ID
+	accepted.Status = job.Snapshot().Status
+	accepted.Position = h.jobManager.Position(job.ID)
+	accepted.Links.Self = "/api/guesses/" + job.ID
+	accepted.Links.Events = "/api/guesses/" + job.ID + "/events"
+
+	w.Header().Set("Location", accepted.Links.Self)
+	response.JSON(w, http.StatusAccepted, accepted)
+}
+
+// handleGetGuess returns a job's status, progress log and result
+func (h *Handler) handleGetGuess(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobManager.Get(r.PathValue("id"))
+	if !ok {
+		response.Error(w, http.StatusNotFound, "guess not found")
+		return
+	}
+
+	response.JSON(w, http.StatusOK, job.Snapshot())
+}
+
+// handleGuessEvents streams a job's progress as Server-Sent Events. Progress
+// emitted before the client connected is replayed first; the stream ends with
+// a "result" or "error" event.
+func (h *Handler) handleGuessEvents(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.jobManager.Get(r.PathValue("id"))
+	if !ok {
+		response.Error(w, http.StatusNotFound, "guess not found")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.Error(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	replay, events, cancel := job.Subscribe()
+	defer cancel()
+
+	for _, event := range replay {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	shutdown := h.shutdown
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-shutdown:
+			if job.Snapshot().Status == jobs.StatusQueued {
+				fmt.Fprint(w, ": server shutting down, the guess will resume after restart\n\n")
+				flusher.Flush()
+				return
+			}
+			shutdown = nil
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event
+func writeEvent(w http.ResponseWriter, event jobs.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("Error marshaling job event: %v", err)
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
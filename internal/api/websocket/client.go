@@ -0,0 +1,247 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"wallet-guesser/internal/api/protocol"
+	"wallet-guesser/internal/game"
+)
+
+const (
+	// writeWait is the time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong message from the peer
+	pongWait = 60 * time.Second
+	// pingPeriod is how often pings are sent; it must be less than pongWait
+	pingPeriod = (pongWait * 9) / 10
+	// maxMessageSize is the maximum size of a message read from the peer
+	maxMessageSize = 64 * 1024
+	// sendQueueSize is the number of outbound messages buffered per connection
+	sendQueueSize = 64
+)
+
+var (
+	// errClientClosed is returned when sending to a connection that has gone away
+	errClientClosed = errors.New("websocket client closed")
+	// errClientTooSlow is returned when a client cannot keep up with messages that must be delivered
+	errClientTooSlow = errors.New("websocket client too slow")
+)
+
+// client is a single WebSocket connection and the game session it is attached to.
+// gorilla/websocket allows only one concurrent writer, so every outbound message
+// goes through the send queue and is written by the client's writePump goroutine.
+type client struct {
+	conn    *websocket.Conn
+	send    chan *protocol.Envelope
+	done    chan struct{}
+	stopped chan struct{} // closed once writePump has closed the connection
+
+	// queueMutex serialises enqueueing so the coalesced progress message keeps its place
+	queueMutex      sync.Mutex
+	pendingProgress *protocol.Envelope
+	droppedProgress int
+	closeOnce       sync.Once
+	closeMessage    []byte
+
+	// session is only changed by the read goroutine, which may read it freely;
+	// other goroutines must use currentSession
+	sessionMutex sync.Mutex
+	session      *game.Session
+	unsubscribe  func()
+}
+
+// newClient wraps a connection and starts its writer goroutine
+func newClient(conn *websocket.Conn) *client {
+	c := &client{
+		conn:    conn,
+		send:    make(chan *protocol.Envelope, sendQueueSize),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	// Keep the connection alive: every pong extends the read deadline
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	go c.writePump()
+	return c
+}
+
+// Send queues a message that must be delivered. If the queue stays full for
+// longer than writeWait the client is considered too slow and is disconnected.
+func (c *client) Send(message *protocol.Envelope) error {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+
+	// A coalesced progress update was produced before this message, so it goes first
+	if c.pendingProgress != nil {
+		pending := c.pendingProgress
+		c.pendingProgress = nil
+		if err := c.enqueueLocked(pending); err != nil {
+			return err
+		}
+	}
+
+	return c.enqueueLocked(message)
+}
+
+// SendDroppable queues a message that may be coalesced when the client is slow.
+// Only the most recent droppable message is kept while the queue is full.
+func (c *client) SendDroppable(message *protocol.Envelope) error {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+
+	select {
+	case <-c.done:
+		return errClientClosed
+	default:
+	}
+
+	// An older update already waits for the queue to drain, so this one replaces
+	// it rather than overtaking it
+	if c.pendingProgress != nil {
+		c.pendingProgress = message
+		c.droppedProgress++
+		return nil
+	}
+
+	select {
+	case c.send <- message:
+	default:
+		c.pendingProgress = message
+	}
+	return nil
+}
+
+// enqueueLocked waits for room in the send queue. The caller must hold queueMutex.
+func (c *client) enqueueLocked(message *protocol.Envelope) error {
+	timer := time.NewTimer(writeWait)
+	defer timer.Stop()
+
+	select {
+	case c.send <- message:
+		return nil
+	case <-c.done:
+		return errClientClosed
+	case <-timer.C:
+		log.Warnf("Disconnecting slow WebSocket client %s", c.conn.RemoteAddr())
+		c.close()
+		return errClientTooSlow
+	}
+}
+
+// takePendingProgress returns the coalesced progress message, if any
+func (c *client) takePendingProgress() *protocol.Envelope {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+
+	pending := c.pendingProgress
+	c.pendingProgress = nil
+	if c.droppedProgress > 0 {
+		log.Debugf("Coalesced %d progress updates for slow client %s", c.droppedProgress, c.conn.RemoteAddr())
+		c.droppedProgress = 0
+	}
+	return pending
+}
+
+// writePump writes queued messages and periodic pings to the connection.
+// It is the only goroutine that writes to the connection.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		close(c.stopped)
+	}()
+
+	for {
+		select {
+		case message := <-c.send:
+			if err := c.write(message); err != nil {
+				return
+			}
+
+			// Once the queue has drained, deliver the latest coalesced progress update
+			if len(c.send) == 0 {
+				if pending := c.takePendingProgress(); pending != nil {
+					if err := c.write(pending); err != nil {
+						return
+					}
+				}
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.conn.WriteMessage(websocket.CloseMessage, c.closeMessage)
+			return
+		}
+	}
+}
+
+// write sends a single message with a write deadline
+func (c *client) write(message *protocol.Envelope) error {
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := c.conn.WriteJSON(message); err != nil {
+		log.WithError(err).Errorf("Error writing %s message", message.Type)
+		return err
+	}
+	return nil
+}
+
+// close stops the writer, which closes the connection and ends the read loop
+func (c *client) close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// closeWith is close with the code and reason sent in the close frame
+func (c *client) closeWith(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeMessage = websocket.FormatCloseMessage(code, reason)
+		close(c.done)
+	})
+}
+
+// attach subscribes the client to a session's events. When replay is set the
+// events buffered by the session are sent first, so a reconnecting client catches up.
+func (c *client) attach(session *game.Session, replay bool) {
+	c.detach()
+
+	render := func(event game.Event) {
+		c.renderSessionEvent(event)
+	}
+
+	c.sessionMutex.Lock()
+	c.session = session
+	c.sessionMutex.Unlock()
+	if replay {
+		c.unsubscribe = session.Attach(render)
+	} else {
+		c.unsubscribe = session.Subscribe(render)
+	}
+}
+
+// currentSession returns the session the client is attached to, for use outside the read goroutine
+func (c *client) currentSession() *game.Session {
+	c.sessionMutex.Lock()
+	defer c.sessionMutex.Unlock()
+	return c.session
+}
+
+// detach stops rendering the current session's events
+func (c *client) detach() {
+	if c.unsubscribe != nil {
+		c.unsubscribe()
+		c.unsubscribe = nil
+	}
+}
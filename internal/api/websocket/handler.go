@@ -1,29 +1,45 @@
 package websocket
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
 	"sync"
 
 	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
+	"wallet-guesser/internal/api/protocol"
 	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/game"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/metrics"
+	"wallet-guesser/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Handler manages WebSocket connections
 type Handler struct {
 	upgrader            websocket.Upgrader
-	clients             map[*websocket.Conn]bool
+	clients             map[*client]bool
 	mutex               sync.Mutex
-	walletGuesserSvc    domain.WalletGuesserService
-	messageHandlerFuncs map[string]MessageHandlerFunc
+	guesser             game.Guesser
+	verificationSvc     domain.VerificationService
+	sessions            *game.SessionStore
+	messageHandlerFuncs map[protocol.MessageType]MessageHandlerFunc
+	sleeping            bool
 }
 
-// MessageHandlerFunc is a function that handles a specific message type
-type MessageHandlerFunc func(conn *websocket.Conn, payload json.RawMessage) error
+// goingToSleepMessage is shown to players when the server shuts down
+const goingToSleepMessage = "The Jinn is going to sleep. Guesses already under way will finish; come back soon to ask for more!"
+
+// MessageHandlerFunc is a function that handles a specific message type for a client.
+// Returning a *protocol.Error reports it to the client; any other error glitches the Jinn.
+// The context carries the message's tracing span and log fields.
+type MessageHandlerFunc func(ctx context.Context, c *client, message *protocol.Envelope) error
 
-// NewHandler creates a new WebSocket handler
-func NewHandler(walletGuesserSvc domain.WalletGuesserService) *Handler {
+// NewHandler creates a new WebSocket handler whose games live in sessions
+func NewHandler(guesser game.Guesser, verificationSvc domain.VerificationService, sessions *game.SessionStore) *Handler {
 	h := &Handler{
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
@@ -31,14 +47,19 @@ func NewHandler(walletGuesserSvc domain.WalletGuesserService) *Handler {
 				return true
 			},
 		},
-		clients:          make(map[*websocket.Conn]bool),
-		walletGuesserSvc: walletGuesserSvc,
+		clients:         make(map[*client]bool),
+		guesser:         guesser,
+		verificationSvc: verificationSvc,
+		sessions:        sessions,
 	}
 
 	// Register message handlers
-	h.messageHandlerFuncs = map[string]MessageHandlerFunc{
-		"START_GAME": h.handleStartGame,
-		"USER_INPUT": h.handleUserInput,
+	h.messageHandlerFuncs = map[protocol.MessageType]MessageHandlerFunc{
+		protocol.TypeStartGame:           h.handleStartGame,
+		protocol.TypeUserInput:           h.handleUserInput,
+		protocol.TypeRequestVerification: h.handleRequestVerification,
+		protocol.TypeSubmitSignature:     h.handleSubmitSignature,
+		protocol.TypeResumeSession:       h.handleResumeSession,
 	}
 
 	return h
@@ -52,31 +73,38 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		log.Errorf("Failed to upgrade to WebSocket: %v", err)
 		return
 	}
-	defer conn.Close()
+
+	// Every connection starts its own game session, which it may later swap for a resumed one
+	c := newClient(conn)
+	defer c.close()
+	c.attach(h.sessions.Create(), false)
 
 	// Register new client
 	h.mutex.Lock()
-	h.clients[conn] = true
+	h.clients[c] = true
 	h.mutex.Unlock()
+	metrics.ActiveWebSocketConnections.Inc()
 
-	// Remove client when the function returns
+	// Remove client and release its session when the function returns
 	defer func() {
 		h.mutex.Lock()
-		delete(h.clients, conn)
+		delete(h.clients, c)
 		h.mutex.Unlock()
+		metrics.ActiveWebSocketConnections.Dec()
+
+		c.detach()
+		h.sessions.Detach(c.session.ID)
 	}()
 
-	// Send initial state
-	initialState := &domain.GameState{JinnState: domain.JinnStateIdle}
-	if err := SendGameState(conn, initialState); err != nil {
+	// Send initial state, including the session id the client needs to resume later
+	if err := c.SendGameState("", c.session.ID, c.session.Snapshot()); err != nil {
 		log.Errorf("Error sending initial state: %v", err)
 		return
 	}
 
 	// Message handling loop
 	for {
-		var msg domain.WebSocketMessage
-		err := conn.ReadJSON(&msg)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Errorf("WebSocket error: %v", err)
@@ -84,43 +112,174 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 
-		// Process the message based on its type
-		if handlerFunc, ok := h.messageHandlerFuncs[msg.Type]; ok {
-			// Marshal the payload for type-specific handling
-			payloadBytes, err := json.Marshal(msg.Payload)
-			if err != nil {
-				log.Errorf("Error marshaling payload: %v", err)
-				SendJinnState(conn, string(domain.JinnStateGlitched), "The Jinn has encountered an error interpreting your request.")
-				continue
-			}
+		h.handleMessage(c, data)
+	}
+}
 
-			err = handlerFunc(conn, payloadBytes)
-			if err != nil {
-				log.Errorf("Error handling message '%s': %v", msg.Type, err)
-				SendJinnState(conn, string(domain.JinnStateGlitched), "The Jinn has encountered an error processing your request.")
-			}
-		} else {
-			log.Warnf("Unknown message type: %s", msg.Type)
+// handleMessage decodes, validates and dispatches a single client message
+func (h *Handler) handleMessage(c *client, data []byte) {
+	message, protocolErr := protocol.Decode(data)
+	if protocolErr != nil {
+		log.Warnf("Rejected WebSocket message: %v", protocolErr)
+		correlationID := ""
+		if message != nil {
+			correlationID = message.ID
 		}
+		c.SendError(correlationID, protocolErr)
+		return
+	}
+
+	ctx, span := tracing.Start(context.Background(), "websocket."+string(message.Type),
+		attribute.String("ws.message_type", string(message.Type)),
+		attribute.String("ws.message_id", message.ID),
+		attribute.String("session.id", c.session.ID),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	// A client message id doubles as the correlation id of any guess it starts
+	correlationID := message.ID
+	if correlationID == "" {
+		correlationID = logging.NewCorrelationID()
+	}
+	ctx = logging.WithFields(ctx, log.Fields{
+		logging.FieldCorrelationID: correlationID,
+		logging.FieldSession:       c.session.ID,
+	})
+	logger := logging.FromContext(ctx)
+
+	handlerFunc, ok := h.messageHandlerFuncs[message.Type]
+	if !ok {
+		unknownErr := protocol.NewError(protocol.ErrorUnknownType, "unknown message type %q", message.Type)
+		err = unknownErr
+		c.SendError(message.ID, unknownErr)
+		return
 	}
+
+	err = handlerFunc(ctx, c, message)
+	if err == nil {
+		return
+	}
+
+	var handlerErr *protocol.Error
+	if errors.As(err, &handlerErr) {
+		logger.Infof("Error handling message '%s': %v", message.Type, handlerErr)
+		c.SendError(message.ID, handlerErr)
+		return
+	}
+
+	logger.Errorf("Error handling message '%s': %v", message.Type, err)
+	c.SendError(message.ID, protocol.NewError(protocol.ErrorInternal, "the Jinn could not process your %s request", message.Type))
+	c.session.Fail("The Jinn has encountered an error processing your request.")
 }
 
 // handleStartGame handles the START_GAME message
-func (h *Handler) handleStartGame(conn *websocket.Conn, _ json.RawMessage) error {
-	// Set the game state to idle
-	return SendJinnState(conn, string(domain.JinnStateIdle), "I am the Crypto Jinn! I can divine your wallet address from your Twitter handle!")
+func (h *Handler) handleStartGame(_ context.Context, c *client, _ *protocol.Envelope) error {
+	return c.session.Start()
 }
 
-// BroadcastMessage sends a message to all connected clients
-func (h *Handler) BroadcastMessage(message domain.WebSocketMessage) {
+// handleResumeSession reattaches the connection to a session started on an earlier connection
+func (h *Handler) handleResumeSession(ctx context.Context, c *client, message *protocol.Envelope) error {
+	var resumePayload protocol.ResumeSessionPayload
+	if err := message.UnmarshalPayload(&resumePayload); err != nil {
+		return err
+	}
+
+	if resumePayload.SessionID == c.session.ID {
+		return nil
+	}
+
+	session, ok := h.sessions.Resume(resumePayload.SessionID)
+	if !ok {
+		return protocol.NewError(protocol.ErrorSessionNotFound, "session %s has expired or never existed", resumePayload.SessionID)
+	}
+
+	// Leave the session this connection started with and replay the resumed one
+	h.sessions.Detach(c.session.ID)
+	if err := c.SendGameState(message.ID, session.ID, session.Snapshot()); err != nil {
+		return err
+	}
+	c.attach(session, true)
+
+	logging.FromContext(ctx).Infof("Resumed session %s", session.ID)
+	return nil
+}
+
+// GoToSleep tells every connected player the Jinn is going to sleep and stops
+// new guesses from starting. Connections stay open so running guesses can still
+// deliver their results.
+func (h *Handler) GoToSleep() {
+	h.mutex.Lock()
+	h.sleeping = true
+	clients := h.clientsLocked()
+	h.mutex.Unlock()
+
+	sendToAll(clients, func(c *client) {
+		if err := c.SendJinnState(string(c.currentSession().State()), goingToSleepMessage); err != nil {
+			log.Warnf("Error sending shutdown notice: %v", err)
+		}
+	})
+}
+
+// IsSleeping reports whether GoToSleep has been called
+func (h *Handler) IsSleeping() bool {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
-	for conn := range h.clients {
-		if err := conn.WriteJSON(message); err != nil {
+	return h.sleeping
+}
+
+// CloseConnections closes every connection with a "going away" close frame and
+// waits for the frames to be written
+func (h *Handler) CloseConnections() {
+	h.mutex.Lock()
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		c.closeWith(websocket.CloseGoingAway, "server shutting down")
+		clients = append(clients, c)
+	}
+	h.mutex.Unlock()
+
+	for _, c := range clients {
+		<-c.stopped
+	}
+	log.Infof("Closed %d WebSocket connections", len(clients))
+}
+
+// BroadcastMessage sends a message to all connected clients
+func (h *Handler) BroadcastMessage(message *protocol.Envelope) {
+	h.mutex.Lock()
+	clients := h.clientsLocked()
+	h.mutex.Unlock()
+
+	// A failed client is closed, and its read loop unregisters it
+	sendToAll(clients, func(c *client) {
+		if err := c.Send(message); err != nil {
 			log.Errorf("Error broadcasting message: %v", err)
-			conn.Close()
-			delete(h.clients, conn)
+			c.close()
 		}
+	})
+}
+
+// clientsLocked copies the connected clients. The caller must hold the mutex.
+func (h *Handler) clientsLocked() []*client {
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// sendToAll runs send for every client at once, so one slow client delays the
+// others by no more than writeWait, and waits for them all
+func sendToAll(clients []*client, send func(c *client)) {
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			send(c)
+		}()
 	}
+	wg.Wait()
 }
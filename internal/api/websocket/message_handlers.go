@@ -1,81 +1,45 @@
 package websocket
 
 import (
-	"encoding/json"
-	"fmt"
-	"time"
+	"context"
 
-	"github.com/gorilla/websocket"
-	log "github.com/sirupsen/logrus"
-	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/api/protocol"
+	"wallet-guesser/internal/game"
+	"wallet-guesser/internal/logging"
 )
 
 // handleUserInput processes a user's input (Twitter handle)
-func (h *Handler) handleUserInput(conn *websocket.Conn, payload json.RawMessage) error {
-	var inputPayload domain.UserInputPayload
-	if err := json.Unmarshal(payload, &inputPayload); err != nil {
-		return fmt.Errorf("error unmarshaling user input payload: %w", err)
+func (h *Handler) handleUserInput(ctx context.Context, c *client, message *protocol.Envelope) error {
+	var inputPayload protocol.UserInputPayload
+	if err := message.UnmarshalPayload(&inputPayload); err != nil {
+		return err
 	}
 
+	// Asking for a handle is refused while a guess is running, like any other move
 	twitterHandle := inputPayload.Twitter
 	if twitterHandle == "" {
-		return SendJinnState(conn, string(domain.JinnStateAsking), "The Jinn needs a Twitter handle to divine the wallet address.")
+		return c.session.AskForHandle()
 	}
 
-	// Start the wallet guessing process in a goroutine
-	go h.processWalletGuess(conn, twitterHandle)
-
-	return nil
-}
-
-// processWalletGuess handles the wallet guessing process
-func (h *Handler) processWalletGuess(conn *websocket.Conn, twitterHandle string) {
-	// First, update the UI to show we're thinking
-	if err := SendJinnState(conn, string(domain.JinnStateThinking), "Hmm... I'm consulting the mystical blockchain ledgers..."); err != nil {
-		log.Errorf("Error sending thinking state: %v", err)
-		return
+	if h.IsSleeping() {
+		return protocol.NewError(protocol.ErrorShuttingDown, "the Jinn is going to sleep and cannot start a new guess")
 	}
 
-	// Define a progress callback to update the user
-	progressCallback := func(message string) {
-		log.Infof("[%s] update: %s", twitterHandle, message)
-		// Send progress update to the client
-		if err := SendProgressUpdate(conn, message); err != nil {
-			log.Errorf("Error sending progress update: %v", err)
-		}
+	// Claim the guess before returning, so a second input arriving right after
+	// this one is answered with GUESS_IN_PROGRESS rather than failing in the background
+	if err := c.session.BeginGuess(twitterHandle); err != nil {
+		return err
 	}
+	go h.processWalletGuess(ctx, c.session, twitterHandle)
 
-	// Call the wallet guesser
-	result, err := h.walletGuesserSvc.GuessWallet(twitterHandle, progressCallback)
-	if err != nil {
-		log.Errorf("Error guessing wallet: %v", err)
-		SendJinnState(conn, string(domain.JinnStateWrong), "The crypto spirits are not cooperating today. Please try again later.")
-		return
-	}
-
-	// Process the result
-	if len(result.Addresses) > 0 {
-		// We found potential wallet addresses
-		confidence := result.Confidence
-
-		// Send the result back to the client
-		if err := SendWalletGuesserResult(conn, result); err != nil {
-			log.Errorf("Error sending wallet result: %v", err)
-			return
-		}
+	return nil
+}
 
-		// Update the Jinn state based on confidence
-		if confidence >= 70 {
-			SendJinnState(conn, string(domain.JinnStateConfident), "Aha! I sense strong wallet energy from this Twitter handle!")
-			time.Sleep(1500 * time.Millisecond)
-			SendJinnState(conn, string(domain.JinnStateCorrect), "Behold! I have divined your wallet correctly!")
-		} else if confidence >= 40 {
-			SendJinnState(conn, string(domain.JinnStateAsking), "I sense some wallet energy, but I'm not entirely sure...")
-		} else {
-			SendJinnState(conn, string(domain.JinnStateWrong), "The blockchain spirits have whispered some addresses, but I'm uncertain...")
-		}
-	} else {
-		// No wallet addresses found
-		SendJinnState(conn, string(domain.JinnStateWrong), "I could not divine any wallet addresses for this Twitter handle.")
+// processWalletGuess runs the guess claimed for a session. The session decides
+// the Jinn's states; the connection only renders the events it emits.
+func (h *Handler) processWalletGuess(ctx context.Context, session *game.Session, twitterHandle string) {
+	// The guess outlives the connection so a reconnecting client can resume it
+	if err := session.RunGuess(context.WithoutCancel(ctx), h.guesser); err != nil {
+		logging.FromContext(ctx).WithField(logging.FieldHandle, twitterHandle).Error(err)
 	}
 }
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"wallet-guesser/internal/api/protocol"
+	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/game"
+)
+
+// blockingGuesser holds every guess until release is closed
+type blockingGuesser struct {
+	release chan struct{}
+}
+
+func (g *blockingGuesser) GuessWallet(ctx context.Context, _ string, _ domain.ProgressCallback) (*domain.WalletGuessResult, error) {
+	<-g.release
+	return &domain.WalletGuessResult{}, nil
+}
+
+// dial starts a handler behind a test server and connects a client to it
+func dial(t *testing.T, h *Handler) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(h.HandleWebSocket))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendUserInput(t *testing.T, conn *websocket.Conn, twitter string) *protocol.Envelope {
+	t.Helper()
+	message, err := protocol.NewEnvelope(protocol.TypeUserInput, protocol.UserInputPayload{Twitter: twitter})
+	if err != nil {
+		t.Fatalf("NewEnvelope: %v", err)
+	}
+	if err := conn.WriteJSON(message); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	return message
+}
+
+func TestSecondGuessIsRejectedWithAnError(t *testing.T) {
+	guesser := &blockingGuesser{release: make(chan struct{})}
+	defer close(guesser.release)
+	h := NewHandler(guesser, nil, game.NewSessionStore(game.DefaultSessionTTL))
+	conn := dial(t, h)
+
+	// Both inputs are written before the server reads either of them
+	first := sendUserInput(t, conn, "alice")
+	second := sendUserInput(t, conn, "bob")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var envelope protocol.Envelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			t.Fatalf("no error reply to the second guess: %v", err)
+		}
+		if envelope.Type != protocol.TypeError {
+			continue
+		}
+
+		var payload protocol.ErrorPayload
+		if err := envelope.UnmarshalPayload(&payload); err != nil {
+			t.Fatalf("UnmarshalPayload: %v", err)
+		}
+		if envelope.CorrelationID == first.ID {
+			t.Fatalf("first guess was rejected: %+v", payload)
+		}
+		if envelope.CorrelationID != second.ID || payload.Code != protocol.ErrorGuessInProgress {
+			t.Fatalf("unexpected error %+v for message %s", payload, envelope.CorrelationID)
+		}
+		return
+	}
+}
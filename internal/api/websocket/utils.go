@@ -1,56 +1,90 @@
 package websocket
 
 import (
-	"github.com/gorilla/websocket"
-	log "github.com/sirupsen/logrus"
+	"time"
+
+	"wallet-guesser/internal/api/protocol"
 	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/game"
 )
 
-// SendGameState sends the current game state to the client
-func SendGameState(conn *websocket.Conn, state *domain.GameState) error {
-	return conn.WriteJSON(domain.WebSocketMessage{
-		Type:    "GAME_STATE",
-		Payload: state,
-	})
+// renderSessionEvent queues a session event for the client
+func (c *client) renderSessionEvent(event game.Event) {
+	switch event.Type {
+	case game.EventStateChanged:
+		c.SendJinnState(string(event.State), event.Message)
+	case game.EventProgress:
+		c.SendProgressUpdate(event.CorrelationID, event.Message, event.Exclusion)
+	case game.EventResult:
+		c.SendWalletGuesserResult(event.CorrelationID, event.Result)
+	}
 }
 
-// SendJinnState sends a jinn state update to the client
-func SendJinnState(conn *websocket.Conn, state string, message string) error {
-	err := conn.WriteJSON(domain.WebSocketMessage{
-		Type: "JINN_STATE",
-		Payload: domain.JinnStatePayload{
-			State:   state,
-			Message: message,
-		},
-	})
+// sendMessage wraps a payload in an envelope and queues it. correlationID
+// links a reply to the client message it answers and may be empty.
+func (c *client) sendMessage(messageType protocol.MessageType, correlationID string, payload interface{}) error {
+	envelope, err := protocol.NewEnvelope(messageType, payload)
 	if err != nil {
-		log.WithError(err).Error("Error sending jinn state")
+		return err
 	}
-	return err
+	envelope.CorrelationID = correlationID
+	return c.Send(envelope)
+}
+
+// SendError sends an ERROR message describing why a client message was rejected
+func (c *client) SendError(correlationID string, protocolErr *protocol.Error) error {
+	return c.sendMessage(protocol.TypeError, correlationID, protocolErr.Payload())
+}
+
+// SendGameState sends the current game state and the session it belongs to
+func (c *client) SendGameState(correlationID string, sessionID string, state *domain.GameState) error {
+	state.SessionID = sessionID
+	return c.sendMessage(protocol.TypeGameState, correlationID, state)
+}
+
+// SendJinnState sends a jinn state update to the client
+func (c *client) SendJinnState(state string, message string) error {
+	return c.sendMessage(protocol.TypeJinnState, "", protocol.JinnStatePayload{
+		State:   state,
+		Message: message,
+	})
 }
 
-// SendProgressUpdate sends a progress update to the client
-func SendProgressUpdate(conn *websocket.Conn, message string) error {
-	err := conn.WriteJSON(domain.WebSocketMessage{
-		Type: "PROGRESS_UPDATE",
-		Payload: domain.ProgressMessage{
-			Message: message,
-		},
+// SendProgressUpdate sends a progress update for the guess identified by correlationID.
+// exclusion explains the candidate the update is about, if the avoid list excluded one.
+// Progress updates are coalesced rather than queued when the client falls behind.
+func (c *client) SendProgressUpdate(correlationID string, message string, exclusion *domain.AvoidReason) error {
+	envelope, err := protocol.NewEnvelope(protocol.TypeProgressUpdate, protocol.ProgressPayload{
+		Message:   message,
+		Exclusion: exclusion,
 	})
 	if err != nil {
-		log.WithError(err).Error("Error sending progress update")
+		return err
 	}
-	return err
+	envelope.CorrelationID = correlationID
+	return c.SendDroppable(envelope)
+}
+
+// SendWalletGuesserResult sends the result of the guess identified by correlationID
+func (c *client) SendWalletGuesserResult(correlationID string, result *domain.WalletGuessResult) error {
+	return c.sendMessage(protocol.TypeWalletResult, correlationID, result)
 }
 
-// SendWalletGuesserResult sends the wallet guesser result to the client
-func SendWalletGuesserResult(conn *websocket.Conn, result *domain.WalletGuessResult) error {
-	err := conn.WriteJSON(domain.WebSocketMessage{
-		Type:    "WALLET_RESULT",
-		Payload: result,
+// SendVerificationChallenge sends a wallet ownership challenge to the client
+func (c *client) SendVerificationChallenge(correlationID string, challenge *domain.VerificationChallenge) error {
+	return c.sendMessage(protocol.TypeVerificationChallenge, correlationID, protocol.VerificationChallengePayload{
+		Nonce:     challenge.Nonce,
+		Address:   challenge.Address,
+		Message:   challenge.Message,
+		ExpiresAt: challenge.ExpiresAt.Format(time.RFC3339),
+	})
+}
+
+// SendVerificationResult sends the outcome of a signature check to the client
+func (c *client) SendVerificationResult(correlationID string, address string, verified bool, message string) error {
+	return c.sendMessage(protocol.TypeVerificationResult, correlationID, protocol.VerificationResultPayload{
+		Address:  address,
+		Verified: verified,
+		Message:  message,
 	})
-	if err != nil {
-		log.WithError(err).Error("Error sending wallet guess result")
-	}
-	return err
 }
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+
+	"wallet-guesser/internal/api/protocol"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/verification"
+)
+
+// handleRequestVerification issues a nonce the player must sign with a guessed wallet
+func (h *Handler) handleRequestVerification(_ context.Context, c *client, message *protocol.Envelope) error {
+	var requestPayload protocol.VerificationRequestPayload
+	if err := message.UnmarshalPayload(&requestPayload); err != nil {
+		return err
+	}
+
+	if h.verificationSvc == nil {
+		return protocol.NewError(protocol.ErrorVerificationFailed, "wallet verification is not available")
+	}
+
+	// Only addresses the Jinn actually guessed can be verified
+	result := c.session.Result()
+	if result == nil || !c.session.HasGuessed(requestPayload.Address) {
+		return protocol.NewError(protocol.ErrorVerificationFailed, "the Jinn did not divine address %s for you", requestPayload.Address)
+	}
+
+	challenge, err := h.verificationSvc.IssueChallenge(c.session.ID, result.TwitterHandle, requestPayload.Address)
+	if err != nil {
+		return protocol.NewError(protocol.ErrorVerificationFailed, "could not issue challenge: %v", err)
+	}
+
+	return c.SendVerificationChallenge(message.ID, challenge)
+}
+
+// handleSubmitSignature verifies a signed challenge and confirms the guess if it checks out
+func (h *Handler) handleSubmitSignature(ctx context.Context, c *client, message *protocol.Envelope) error {
+	var signaturePayload protocol.SignatureSubmissionPayload
+	if err := message.UnmarshalPayload(&signaturePayload); err != nil {
+		return err
+	}
+
+	if h.verificationSvc == nil {
+		return protocol.NewError(protocol.ErrorVerificationFailed, "wallet verification is not available")
+	}
+
+	// A nonce issued to another session proves nothing about this one, and does not count against its guess
+	verified, err := h.verificationSvc.VerifySignature(c.session.ID, signaturePayload.Nonce, signaturePayload.Signature)
+	if errors.Is(err, verification.ErrWrongSession) {
+		return protocol.NewError(protocol.ErrorVerificationFailed, "that challenge was not issued to this session")
+	}
+	if err != nil {
+		logging.FromContext(ctx).Infof("Wallet verification failed: %v", err)
+		if err := c.SendVerificationResult(message.ID, "", false, "The signature could not be verified."); err != nil {
+			return err
+		}
+		return c.session.RejectVerification()
+	}
+
+	logging.FromContext(ctx).WithField(logging.FieldHandle, verified.TwitterHandle).Infof("Verified ownership of %s", verified.Address)
+	if err := c.SendVerificationResult(message.ID, verified.Address, true, "Wallet ownership verified."); err != nil {
+		return err
+	}
+	return c.session.ConfirmVerified(verified)
+}
@@ -0,0 +1,204 @@
+package avoidlist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"wallet-guesser/internal/domain"
+)
+
+// The binary avoid list format is written for files ending in .bin. At millions
+// of entries it loads several times faster than JSON and is less than half the size.
+// All integers are little endian.
+//
+//	header      magic "WGAL", version uint16, reserved uint16, entry count uint32,
+//	            CRC-32C of everything after the header uint32, last updated unix nanoseconds int64
+//	categories  count uint8, then per category: length uint8, name
+//	keys        per entry, sorted ascending: length uint8, address or prefix
+//	types       bitmap, one bit per entry in key order, set for wallets and clear for tokens
+//	category    per entry, one byte: 0 for none, otherwise the position in the category table plus one
+const (
+	binaryMagic         = "WGAL"
+	binaryFormatVersion = 1
+	binaryHeaderSize    = 24
+
+	// BinaryExtension is the extension of avoid list files written in the binary format
+	BinaryExtension = ".bin"
+)
+
+// crcTable is the Castagnoli polynomial, which most CPUs compute in hardware
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// isBinaryFile reports whether file contents start with the binary format's magic
+func isBinaryFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(binaryMagic))
+}
+
+// encodeBinary writes entries in the binary format. Only token and wallet
+// entries can be stored, and keys and categories must fit a length byte.
+func encodeBinary(entries []domain.AvoidListEntry, lastUpdated time.Time) ([]byte, error) {
+	var categories []domain.AvoidCategory
+	categoryCodes := make(map[domain.AvoidCategory]byte)
+	for _, entry := range entries {
+		if entry.Category == "" {
+			continue
+		}
+		if _, ok := categoryCodes[entry.Category]; ok {
+			continue
+		}
+		if len(categories) == 255 {
+			return nil, fmt.Errorf("more than 255 categories")
+		}
+		if len(entry.Category) > 255 {
+			return nil, fmt.Errorf("category %q is too long", entry.Category)
+		}
+		categories = append(categories, entry.Category)
+		categoryCodes[entry.Category] = byte(len(categories))
+	}
+
+	var body bytes.Buffer
+	body.WriteByte(byte(len(categories)))
+	for _, category := range categories {
+		body.WriteByte(byte(len(category)))
+		body.WriteString(string(category))
+	}
+
+	types := make([]byte, (len(entries)+7)/8)
+	previous := ""
+	for i, entry := range entries {
+		key := entry.Key()
+		if len(key) > 255 {
+			return nil, fmt.Errorf("key %q is too long", key)
+		}
+		if i > 0 && key <= previous {
+			return nil, fmt.Errorf("entries are not sorted by key at %q", key)
+		}
+		previous = key
+		body.WriteByte(byte(len(key)))
+		body.WriteString(key)
+
+		switch entry.Type {
+		case "w":
+			types[i/8] |= 1 << (i % 8)
+		case "t":
+		default:
+			return nil, fmt.Errorf("entry %s has type %q, only \"t\" and \"w\" can be stored", key, entry.Type)
+		}
+	}
+	body.Write(types)
+	for _, entry := range entries {
+		body.WriteByte(categoryCodes[entry.Category])
+	}
+
+	data := make([]byte, binaryHeaderSize, binaryHeaderSize+body.Len())
+	copy(data, binaryMagic)
+	binary.LittleEndian.PutUint16(data[4:], binaryFormatVersion)
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(entries)))
+	binary.LittleEndian.PutUint32(data[12:], crc32.Checksum(body.Bytes(), crcTable))
+	if !lastUpdated.IsZero() {
+		binary.LittleEndian.PutUint64(data[16:], uint64(lastUpdated.UnixNano()))
+	}
+	return append(data, body.Bytes()...), nil
+}
+
+// errTruncated is returned for binary files that end before their entries do
+var errTruncated = errors.New("binary avoid list is truncated")
+
+// decodeBinary reads a file written by encodeBinary, checking its checksum and key order
+func decodeBinary(data []byte) (*avoidListFile, error) {
+	if len(data) < binaryHeaderSize || !isBinaryFile(data) {
+		return nil, fmt.Errorf("not a binary avoid list")
+	}
+	version := binary.LittleEndian.Uint16(data[4:])
+	if version > binaryFormatVersion {
+		return nil, fmt.Errorf("binary avoid list version %d is newer than the supported version %d", version, binaryFormatVersion)
+	}
+	count := int(binary.LittleEndian.Uint32(data[8:]))
+	body := data[binaryHeaderSize:]
+	if checksum := crc32.Checksum(body, crcTable); checksum != binary.LittleEndian.Uint32(data[12:]) {
+		return nil, fmt.Errorf("binary avoid list checksum mismatch, the file is corrupt")
+	}
+
+	fileData := &avoidListFile{Version: fileFormatVersion}
+	if nanos := int64(binary.LittleEndian.Uint64(data[16:])); nanos != 0 {
+		fileData.LastUpdated = time.Unix(0, nanos).UTC()
+	}
+
+	// readString reads a length-prefixed string from the body
+	offset := 0
+	readString := func() (string, error) {
+		if offset >= len(body) {
+			return "", errTruncated
+		}
+		length := int(body[offset])
+		offset++
+		if offset+length > len(body) {
+			return "", errTruncated
+		}
+		value := string(body[offset : offset+length])
+		offset += length
+		return value, nil
+	}
+
+	if offset >= len(body) {
+		return nil, errTruncated
+	}
+	categories := make([]domain.AvoidCategory, body[offset])
+	offset++
+	for i := range categories {
+		name, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		categories[i] = domain.AvoidCategory(name)
+	}
+
+	// The header's count is only trusted as far as the body backs it up
+	if count > len(body) {
+		return nil, errTruncated
+	}
+	fileData.Entries = make([]domain.AvoidListEntry, count)
+	previous := ""
+	for i := range fileData.Entries {
+		key, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && key <= previous {
+			return nil, fmt.Errorf("binary avoid list keys are not sorted at entry %d", i)
+		}
+		previous = key
+		if len(key) >= MinAddressLength {
+			fileData.Entries[i].Address = key
+		} else {
+			fileData.Entries[i].Prefix = key
+		}
+	}
+
+	types := (count + 7) / 8
+	if offset+types+count != len(body) {
+		return nil, errTruncated
+	}
+	for i := range fileData.Entries {
+		if body[offset+i/8]&(1<<(i%8)) != 0 {
+			fileData.Entries[i].Type = "w"
+		} else {
+			fileData.Entries[i].Type = "t"
+		}
+	}
+	offset += types
+	for i := range fileData.Entries {
+		code := int(body[offset+i])
+		if code > len(categories) {
+			return nil, fmt.Errorf("binary avoid list entry %d has unknown category %d", i, code)
+		}
+		if code > 0 {
+			fileData.Entries[i].Category = categories[code-1]
+		}
+	}
+	return fileData, nil
+}
@@ -0,0 +1,262 @@
+package avoidlist
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet-guesser/internal/domain"
+)
+
+const (
+	testAddress = "So11111111111111111111111111111111111111112"
+	testWallet  = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+)
+
+// testEntries is a sorted mix of tokens, wallets, prefixes, addresses and categories
+var testEntries = []domain.AvoidListEntry{
+	{Prefix: "3NZ9JMVB", Type: "t"},
+	{Prefix: "7xKXtg2C", Type: "w", Category: domain.AvoidCategory("exchange")},
+	{Prefix: "7xKXtg2CW87d97TX", Type: "w", Category: domain.AvoidCategory("market_maker")},
+	{Address: testWallet, Type: "w", Category: domain.AvoidCategory("exchange")},
+	{Address: testAddress, Type: "t"},
+}
+
+// withChecksum rewrites the header checksum after a test has altered the body
+func withChecksum(data []byte) []byte {
+	binary.LittleEndian.PutUint32(data[12:], crc32.Checksum(data[binaryHeaderSize:], crcTable))
+	return data
+}
+
+func encodeTestEntries(t *testing.T) []byte {
+	t.Helper()
+	data, err := encodeBinary(testEntries, time.Unix(1700000000, 123).UTC())
+	if err != nil {
+		t.Fatalf("encodeBinary: %v", err)
+	}
+	return data
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []domain.AvoidListEntry
+		lastUpdated time.Time
+	}{
+		{"empty", []domain.AvoidListEntry{}, time.Time{}},
+		{"single token prefix", testEntries[:1], time.Unix(1700000000, 0).UTC()},
+		{"mixed", testEntries, time.Unix(1700000000, 123).UTC()},
+		{"never updated", testEntries, time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := encodeBinary(test.entries, test.lastUpdated)
+			if err != nil {
+				t.Fatalf("encodeBinary: %v", err)
+			}
+			if !isBinaryFile(data) {
+				t.Fatal("encoded data does not start with the magic")
+			}
+
+			decoded, err := decodeBinary(data)
+			if err != nil {
+				t.Fatalf("decodeBinary: %v", err)
+			}
+			if !reflect.DeepEqual(decoded.Entries, test.entries) {
+				t.Errorf("entries = %+v, want %+v", decoded.Entries, test.entries)
+			}
+			if !decoded.LastUpdated.Equal(test.lastUpdated) {
+				t.Errorf("lastUpdated = %s, want %s", decoded.LastUpdated, test.lastUpdated)
+			}
+		})
+	}
+}
+
+func TestEncodeBinaryRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []domain.AvoidListEntry
+	}{
+		{"unsorted", []domain.AvoidListEntry{testEntries[1], testEntries[0]}},
+		{"duplicate", []domain.AvoidListEntry{testEntries[0], testEntries[0]}},
+		{"unknown type", []domain.AvoidListEntry{{Prefix: "3NZ9JMVB", Type: "x"}}},
+		{"long key", []domain.AvoidListEntry{{Address: strings.Repeat("a", 256), Type: "t"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := encodeBinary(test.entries, time.Time{}); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryRejects(t *testing.T) {
+	valid := encodeTestEntries(t)
+	mutate := func(change func(data []byte) []byte) []byte {
+		return change(append([]byte(nil), valid...))
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		truncated bool
+		contains  string
+	}{
+		{"empty", nil, false, "not a binary avoid list"},
+		{"header only", valid[:binaryHeaderSize-1], false, "not a binary avoid list"},
+		{"bad magic", mutate(func(data []byte) []byte { data[0] = 'X'; return data }), false, "not a binary avoid list"},
+		{"newer version", mutate(func(data []byte) []byte {
+			binary.LittleEndian.PutUint16(data[4:], binaryFormatVersion+1)
+			return data
+		}), false, "newer than the supported version"},
+		{"checksum mismatch", mutate(func(data []byte) []byte { data[len(data)-1] ^= 0xff; return data }), false, "checksum mismatch"},
+		{"truncated body", withChecksum(append([]byte(nil), valid[:len(valid)-3]...)), true, ""},
+		{"trailing bytes", mutate(func(data []byte) []byte { return withChecksum(append(data, 0)) }), true, ""},
+		{"count too high", mutate(func(data []byte) []byte {
+			binary.LittleEndian.PutUint32(data[8:], uint32(len(testEntries)+1))
+			return data
+		}), true, ""},
+		{"unsorted keys", mutate(func(data []byte) []byte {
+			// Swap the first letter of the first key so it sorts after the second
+			first := binaryHeaderSize + len(categoriesOf(testEntries)) + 1
+			for _, category := range categoriesOf(testEntries) {
+				first += len(category)
+			}
+			data[first+1] = 'z'
+			return withChecksum(data)
+		}), false, "not sorted"},
+		{"unknown category", mutate(func(data []byte) []byte {
+			data[len(data)-1] = 99
+			return withChecksum(data)
+		}), false, "unknown category"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decodeBinary(test.data)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if test.truncated && !errors.Is(err, errTruncated) {
+				t.Fatalf("error = %v, want %v", err, errTruncated)
+			}
+			if test.contains != "" && !strings.Contains(err.Error(), test.contains) {
+				t.Fatalf("error = %v, want it to mention %q", err, test.contains)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryRejectsEveryTruncation(t *testing.T) {
+	valid := encodeTestEntries(t)
+	for length := binaryHeaderSize; length < len(valid); length++ {
+		data := withChecksum(append([]byte(nil), valid[:length]...))
+		if _, err := decodeBinary(data); err == nil {
+			t.Fatalf("decoding the first %d of %d bytes succeeded", length, len(valid))
+		}
+	}
+}
+
+// categoriesOf lists the distinct categories in the order encodeBinary stores them
+func categoriesOf(entries []domain.AvoidListEntry) []domain.AvoidCategory {
+	var categories []domain.AvoidCategory
+	seen := make(map[domain.AvoidCategory]bool)
+	for _, entry := range entries {
+		if entry.Category != "" && !seen[entry.Category] {
+			seen[entry.Category] = true
+			categories = append(categories, entry.Category)
+		}
+	}
+	return categories
+}
+
+func writeJSONList(t *testing.T, path string, fileData any) {
+	t.Helper()
+	data, err := json.Marshal(fileData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFromFileImportsJSONSibling(t *testing.T) {
+	dir := t.TempDir()
+	binPath := filepath.Join(dir, "avoidlist.bin")
+	jsonPath := filepath.Join(dir, "avoidlist.json")
+	lastUpdated := time.Unix(1700000000, 0).UTC()
+	writeJSONList(t, jsonPath, avoidListFile{Version: fileFormatVersion, Entries: testEntries, LastUpdated: lastUpdated})
+
+	service := NewService("", binPath)
+	if err := service.LoadFromFile(); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if len(service.Entries()) != len(testEntries) {
+		t.Fatalf("loaded %d entries, want %d", len(service.Entries()), len(testEntries))
+	}
+
+	fileData, err := readListFile(binPath)
+	if err != nil {
+		t.Fatalf("the binary list was not written: %v", err)
+	}
+	if !reflect.DeepEqual(fileData.Entries, testEntries) || !fileData.LastUpdated.Equal(lastUpdated) {
+		t.Fatalf("binary list holds %+v updated at %s", fileData.Entries, fileData.LastUpdated)
+	}
+	if _, err := os.Stat(jsonPath); err != nil {
+		t.Fatalf("the JSON list was removed: %v", err)
+	}
+
+	// The binary list is preferred once it exists
+	writeJSONList(t, jsonPath, avoidListFile{Version: fileFormatVersion, LastUpdated: lastUpdated})
+	reloaded := NewService("", binPath)
+	if err := reloaded.LoadFromFile(); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if len(reloaded.Entries()) != len(testEntries) {
+		t.Fatalf("reloaded %d entries, want %d", len(reloaded.Entries()), len(testEntries))
+	}
+}
+
+func TestImportAndExport(t *testing.T) {
+	dir := t.TempDir()
+	service := NewService("", filepath.Join(dir, "avoidlist.bin"))
+
+	// Version 1 files have no version field; invalid entries are dropped and counted
+	v1Path := filepath.Join(dir, "v1.json")
+	writeJSONList(t, v1Path, map[string]any{
+		"entries": []domain.AvoidListEntry{
+			{Prefix: "3NZ9JMVB", Type: "t"},
+			{Prefix: "short", Type: "t"},
+			{Prefix: "7xKXtg2C", Type: "w"},
+		},
+	})
+	imported, dropped, err := service.Import(v1Path)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if imported != 2 || dropped != 1 {
+		t.Fatalf("imported %d and dropped %d, want 2 and 1", imported, dropped)
+	}
+
+	for _, name := range []string{"export.json", "export.bin"} {
+		exportPath := filepath.Join(dir, name)
+		if _, err := service.Export(exportPath); err != nil {
+			t.Fatalf("Export %s: %v", name, err)
+		}
+		other := NewService("", filepath.Join(dir, "other.bin"))
+		if imported, _, err := other.Import(exportPath); err != nil || imported != 2 {
+			t.Fatalf("Import %s imported %d entries: %v", name, imported, err)
+		}
+		if !reflect.DeepEqual(other.Entries(), service.Entries()) {
+			t.Fatalf("%s round trip changed the entries", name)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package avoidlist
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"wallet-guesser/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultHolderThreshold is the holder count above which a token is avoided
+	DefaultHolderThreshold = 100_000
+	// DefaultTokenThreshold is the token count above which a wallet is avoided
+	DefaultTokenThreshold = 500
+	// DefaultMinAppearances is how many cached holder lists a wallet must be in to be counted
+	DefaultMinAppearances = 3
+	// DefaultBuildConcurrency is the number of RPC requests a build runs at once
+	DefaultBuildConcurrency = 4
+)
+
+// ChainCounter counts token holders and wallet holdings on chain
+type ChainCounter interface {
+	CountTokenHolders(ctx context.Context, mintAddress string) (int, error)
+	CountWalletTokens(ctx context.Context, walletAddress string) (int, error)
+}
+
+// Builder builds avoid list entries from on-chain data, as an alternative to
+// the Dune query. Tokens are counted directly; wallets are only counted when
+// they show up in several cached holder lists, since a wallet holding hundreds
+// of tokens turns up everywhere and counting every holder would take days.
+type Builder struct {
+	counter         ChainCounter
+	holderThreshold int
+	tokenThreshold  int
+	minAppearances  int
+	concurrency     int
+}
+
+// BuilderOption is a functional option for configuring the builder
+type BuilderOption func(*Builder)
+
+// WithHolderThreshold sets the holder count above which a token is avoided
+func WithHolderThreshold(threshold int) BuilderOption {
+	return func(b *Builder) {
+		b.holderThreshold = threshold
+	}
+}
+
+// WithTokenThreshold sets the token count above which a wallet is avoided
+func WithTokenThreshold(threshold int) BuilderOption {
+	return func(b *Builder) {
+		b.tokenThreshold = threshold
+	}
+}
+
+// WithMinAppearances sets how many cached holder lists a wallet must be in to be counted
+func WithMinAppearances(appearances int) BuilderOption {
+	return func(b *Builder) {
+		b.minAppearances = appearances
+	}
+}
+
+// WithBuildConcurrency sets the number of RPC requests a build runs at once
+func WithBuildConcurrency(concurrency int) BuilderOption {
+	return func(b *Builder) {
+		if concurrency > 0 {
+			b.concurrency = concurrency
+		}
+	}
+}
+
+// BuildResult is the outcome of a build
+type BuildResult struct {
+	Entries        []domain.AvoidListEntry
+	TokensChecked  int
+	WalletsChecked int
+	Failures       int
+}
+
+// NewBuilder creates a builder, using the default thresholds unless overridden
+func NewBuilder(counter ChainCounter, options ...BuilderOption) *Builder {
+	b := &Builder{
+		counter:         counter,
+		holderThreshold: DefaultHolderThreshold,
+		tokenThreshold:  DefaultTokenThreshold,
+		minAppearances:  DefaultMinAppearances,
+		concurrency:     DefaultBuildConcurrency,
+	}
+
+	for _, option := range options {
+		option(b)
+	}
+
+	return b
+}
+
+// Build counts the holders of every token in holders, and the tokens of every
+// wallet appearing in at least minAppearances of the holder lists, returning
+// full address entries for those over the thresholds. Failed counts are logged
+// and skipped so one bad account does not sink the whole build.
+func (b *Builder) Build(ctx context.Context, holders map[string][]string) (*BuildResult, error) {
+	mints := make([]string, 0, len(holders))
+	appearances := make(map[string]int)
+	for mintAddress, wallets := range holders {
+		mints = append(mints, mintAddress)
+		for _, wallet := range wallets {
+			appearances[wallet]++
+		}
+	}
+	var wallets []string
+	for wallet, count := range appearances {
+		if count >= b.minAppearances {
+			wallets = append(wallets, wallet)
+		}
+	}
+	sort.Strings(mints)
+	sort.Strings(wallets)
+
+	log.Infof("Counting holders of %d tokens and tokens of %d wallets", len(mints), len(wallets))
+	result := &BuildResult{TokensChecked: len(mints), WalletsChecked: len(wallets)}
+
+	tokens, failures := b.countAll(ctx, mints, "t", b.counter.CountTokenHolders, b.holderThreshold)
+	result.Entries = append(result.Entries, tokens...)
+	result.Failures += failures
+
+	heavyWallets, failures := b.countAll(ctx, wallets, "w", b.counter.CountWalletTokens, b.tokenThreshold)
+	result.Entries = append(result.Entries, heavyWallets...)
+	result.Failures += failures
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// countAll runs count for each address on concurrency workers and returns an
+// entry of the given type for each address whose count exceeds threshold
+func (b *Builder) countAll(ctx context.Context, addresses []string, entryType string,
+	count func(context.Context, string) (int, error), threshold int) ([]domain.AvoidListEntry, int) {
+	work := make(chan string)
+	var mutex sync.Mutex
+	var entries []domain.AvoidListEntry
+	failures, done := 0, 0
+
+	var workers sync.WaitGroup
+	for i := 0; i < b.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for address := range work {
+				n, err := count(ctx, address)
+
+				mutex.Lock()
+				done++
+				if err != nil {
+					failures++
+					log.Warnf("Failed to count %s: %v", address, err)
+				} else if n > threshold {
+					entries = append(entries, domain.AvoidListEntry{Address: address, Type: entryType})
+					log.Debugf("%s is over the threshold with %d", address, n)
+				}
+				if done%100 == 0 {
+					log.Infof("Counted %d/%d", done, len(addresses))
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, address := range addresses {
+		select {
+		case work <- address:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(work)
+	workers.Wait()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Address < entries[j].Address })
+	return entries, failures
+}
@@ -0,0 +1,321 @@
+package avoidlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet-guesser/internal/metrics"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultDuneBaseURL is the Dune API the client talks to
+	DefaultDuneBaseURL = "https://api.dune.com/api/v1"
+	// DefaultDunePageSize is how many rows are requested per results page
+	DefaultDunePageSize = 10000
+	// DefaultDuneRetries is how many times a failed request is retried
+	DefaultDuneRetries = 3
+	// DefaultDunePollInterval is how often a running execution's status is checked
+	DefaultDunePollInterval = 5 * time.Second
+	// DefaultDuneExecutionTimeout bounds how long an execution may take to finish
+	DefaultDuneExecutionTimeout = 10 * time.Minute
+
+	// duneRetryDelay is the delay before the first retry, doubled for each one after
+	duneRetryDelay = 2 * time.Second
+	// duneMaxRetryDelay caps the delay between retries, including Retry-After
+	duneMaxRetryDelay = time.Minute
+)
+
+// Execution states reported by Dune
+const (
+	duneStateCompleted = "QUERY_STATE_COMPLETED"
+	duneStateFailed    = "QUERY_STATE_FAILED"
+	duneStateCancelled = "QUERY_STATE_CANCELLED"
+	duneStateExpired   = "QUERY_STATE_EXPIRED"
+)
+
+// DuneClient fetches query results from the Dune API. It authenticates with
+// the X-Dune-API-Key header, pages through results with next_offset and
+// retries requests that fail with a transport error, 429 or 5xx.
+type DuneClient struct {
+	baseURL          string
+	apiKey           string
+	httpClient       *http.Client
+	pageSize         int
+	retries          int
+	pollInterval     time.Duration
+	executionTimeout time.Duration
+}
+
+// DuneOption is a functional option for configuring the Dune client
+type DuneOption func(*DuneClient)
+
+// WithDuneBaseURL points the client at another Dune API, such as a test server
+func WithDuneBaseURL(baseURL string) DuneOption {
+	return func(c *DuneClient) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithDunePageSize sets how many rows are requested per results page
+func WithDunePageSize(pageSize int) DuneOption {
+	return func(c *DuneClient) {
+		c.pageSize = pageSize
+	}
+}
+
+// WithDuneRetries sets how many times a failed request is retried
+func WithDuneRetries(retries int) DuneOption {
+	return func(c *DuneClient) {
+		c.retries = retries
+	}
+}
+
+// WithDunePolling sets how often an execution's status is checked and how long it may run
+func WithDunePolling(interval time.Duration, timeout time.Duration) DuneOption {
+	return func(c *DuneClient) {
+		c.pollInterval = interval
+		c.executionTimeout = timeout
+	}
+}
+
+// NewDuneClient creates a new Dune API client
+func NewDuneClient(apiKey string, options ...DuneOption) *DuneClient {
+	c := &DuneClient{
+		baseURL:          DefaultDuneBaseURL,
+		apiKey:           apiKey,
+		httpClient:       &http.Client{Timeout: duneTimeout},
+		pageSize:         DefaultDunePageSize,
+		retries:          DefaultDuneRetries,
+		pollInterval:     DefaultDunePollInterval,
+		executionTimeout: DefaultDuneExecutionTimeout,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// duneResults is a page of query or execution results
+type duneResults struct {
+	ExecutionID string `json:"execution_id"`
+	State       string `json:"state"`
+	Result      struct {
+		Rows []json.RawMessage `json:"rows"`
+	} `json:"result"`
+	NextOffset *int `json:"next_offset"`
+}
+
+// duneStatus is the status of an execution
+type duneStatus struct {
+	ExecutionID string `json:"execution_id"`
+	State       string `json:"state"`
+	Error       *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// duneError is the body Dune sends with an unsuccessful status
+type duneError struct {
+	Error string `json:"error"`
+}
+
+// LatestResults returns every row of a query's most recent execution
+func (c *DuneClient) LatestResults(ctx context.Context, queryID int) ([]json.RawMessage, error) {
+	return c.allRows(ctx, "results", fmt.Sprintf("/query/%d/results", queryID))
+}
+
+// Execute starts a fresh execution of a query and returns its id
+func (c *DuneClient) Execute(ctx context.Context, queryID int) (string, error) {
+	var status duneStatus
+	if err := c.do(ctx, "execute", http.MethodPost, fmt.Sprintf("/query/%d/execute", queryID), &status); err != nil {
+		return "", err
+	}
+	if status.ExecutionID == "" {
+		return "", fmt.Errorf("dune did not return an execution id")
+	}
+	return status.ExecutionID, nil
+}
+
+// WaitForExecution polls an execution until it completes, fails or runs past the execution timeout
+func (c *DuneClient) WaitForExecution(ctx context.Context, executionID string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.executionTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		var status duneStatus
+		if err := c.do(ctx, "status", http.MethodGet, fmt.Sprintf("/execution/%s/status", url.PathEscape(executionID)), &status); err != nil {
+			return err
+		}
+
+		switch status.State {
+		case duneStateCompleted:
+			return nil
+		case duneStateFailed, duneStateCancelled, duneStateExpired:
+			if status.Error != nil && status.Error.Message != "" {
+				return fmt.Errorf("dune execution %s ended in %s: %s", executionID, status.State, status.Error.Message)
+			}
+			return fmt.Errorf("dune execution %s ended in %s", executionID, status.State)
+		}
+		log.Debugf("Dune execution %s is %s", executionID, status.State)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for dune execution %s: %w", executionID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExecutionResults returns every row of a completed execution
+func (c *DuneClient) ExecutionResults(ctx context.Context, executionID string) ([]json.RawMessage, error) {
+	return c.allRows(ctx, "results", fmt.Sprintf("/execution/%s/results", url.PathEscape(executionID)))
+}
+
+// ExecuteAndFetch runs a query afresh, waits for it and returns its rows.
+// Executions use Dune credits, unlike reading the latest results.
+func (c *DuneClient) ExecuteAndFetch(ctx context.Context, queryID int) ([]json.RawMessage, error) {
+	executionID, err := c.Execute(ctx, queryID)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Started Dune execution %s of query %d", executionID, queryID)
+
+	if err := c.WaitForExecution(ctx, executionID); err != nil {
+		return nil, err
+	}
+	return c.ExecutionResults(ctx, executionID)
+}
+
+// allRows follows next_offset through every page of results
+func (c *DuneClient) allRows(ctx context.Context, operation string, path string) ([]json.RawMessage, error) {
+	var rows []json.RawMessage
+	offset := 0
+	for {
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(c.pageSize))
+		query.Set("offset", strconv.Itoa(offset))
+
+		var page duneResults
+		if err := c.do(ctx, operation, http.MethodGet, path+"?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		rows = append(rows, page.Result.Rows...)
+
+		if page.NextOffset == nil {
+			return rows, nil
+		}
+		if *page.NextOffset <= offset {
+			return nil, fmt.Errorf("dune returned next_offset %d after offset %d", *page.NextOffset, offset)
+		}
+		offset = *page.NextOffset
+		log.Debugf("Fetched %d Dune rows, continuing from offset %d", len(rows), offset)
+	}
+}
+
+// do sends a request, retrying transport errors, 429 and 5xx with
+// exponential backoff or the delay Dune asks for, and decodes the response
+func (c *DuneClient) do(ctx context.Context, operation string, method string, path string, out interface{}) error {
+	delay := duneRetryDelay
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := c.doOnce(ctx, operation, method, path, out)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= c.retries || ctx.Err() != nil {
+			return err
+		}
+
+		wait := delay
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		wait = min(wait, duneMaxRetryDelay)
+		log.Warnf("Dune %s request failed, retrying in %s: %v", operation, wait, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// permanentError is a failure that retrying will not fix, such as a bad API key
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// doOnce performs a single request for do, returning how long Dune asked to
+// wait before retrying, if it did
+func (c *DuneClient) doOnce(ctx context.Context, operation string, method string, path string, out interface{}) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return 0, &permanentError{fmt.Errorf("failed to create dune request: %w", err)}
+	}
+	req.Header.Set("X-Dune-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		metrics.DuneRequests.WithLabelValues(operation, "transport_error").Inc()
+		return 0, fmt.Errorf("dune %s request failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+	metrics.DuneRequests.WithLabelValues(operation, fmt.Sprintf("http_%d", resp.StatusCode)).Inc()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read dune response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("dune %s returned status %d", operation, resp.StatusCode)
+		var body duneError
+		if json.Unmarshal(data, &body) == nil && body.Error != "" {
+			err = fmt.Errorf("dune %s returned status %d: %s", operation, resp.StatusCode, body.Error)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return retryAfter(resp.Header.Get("Retry-After")), err
+		}
+		return 0, &permanentError{err}
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return 0, &permanentError{fmt.Errorf("failed to unmarshal dune response: %w", err)}
+	}
+	return 0, nil
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
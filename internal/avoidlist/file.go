@@ -0,0 +1,153 @@
+package avoidlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wallet-guesser/internal/domain"
+)
+
+// fileFormatVersion is the version of the JSON avoid list file written by
+// saveToFile. Version 1 files have no version field and hold only 8 character
+// prefixes. Version 2 adds full address entries matched exactly. Binary files
+// are versioned separately, see binaryFormatVersion.
+const fileFormatVersion = 2
+
+// avoidListFile is the persisted form of the avoid list
+type avoidListFile struct {
+	Version     int                     `json:"version,omitempty"`
+	Entries     []domain.AvoidListEntry `json:"entries"`
+	LastUpdated time.Time               `json:"lastUpdated"`
+}
+
+// readListFile reads an avoid list file in either format, recognising binary
+// files by their magic rather than their extension
+func readListFile(filePath string) (*avoidListFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avoid list file: %w", err)
+	}
+	if isBinaryFile(data) {
+		return decodeBinary(data)
+	}
+
+	var fileData avoidListFile
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal avoid list data: %w", err)
+	}
+	return &fileData, nil
+}
+
+// writeListFile writes entries to a file, in the binary format when its name
+// ends in .bin and as JSON otherwise. The file is written to a temporary file
+// first so readers never see a truncated list.
+func writeListFile(filePath string, entries []domain.AvoidListEntry, lastUpdated time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for avoid list: %w", err)
+	}
+
+	var data []byte
+	var err error
+	if isBinaryPath(filePath) {
+		data, err = encodeBinary(entries, lastUpdated)
+	} else {
+		data, err = json.Marshal(avoidListFile{
+			Version:     fileFormatVersion,
+			Entries:     entries,
+			LastUpdated: lastUpdated,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode avoid list data: %w", err)
+	}
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write avoid list file: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace avoid list file: %w", err)
+	}
+	return nil
+}
+
+// isBinaryPath reports whether a file is written in the binary format
+func isBinaryPath(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), BinaryExtension)
+}
+
+// jsonSibling is the JSON file a binary avoid list is imported from when it does not exist yet
+func jsonSibling(filePath string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".json"
+}
+
+// buildEntries keys the file's entries for the service, migrating older
+// formats. Invalid entries, such as prefixes too short to match safely, are
+// dropped and counted.
+func (f *avoidListFile) buildEntries() (map[string]domain.AvoidListEntry, int, error) {
+	if f.Version > fileFormatVersion {
+		return nil, 0, fmt.Errorf("avoid list file version %d is newer than the supported version %d", f.Version, fileFormatVersion)
+	}
+
+	entries := make(map[string]domain.AvoidListEntry, len(f.Entries))
+	dropped := 0
+	for _, entry := range f.Entries {
+		entry, err := normalizeEntry(entry)
+		if err != nil {
+			dropped++
+			continue
+		}
+		entries[entry.Key()] = entry
+	}
+	return entries, dropped, nil
+}
+
+// sortedEntries returns the entries ordered by key, so saved files diff cleanly
+func sortedEntries(entries map[string]domain.AvoidListEntry) []domain.AvoidListEntry {
+	sorted := make([]domain.AvoidListEntry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Key() < sorted[j].Key()
+	})
+	return sorted
+}
+
+// fileStamp identifies a version of a file by its modification time and size
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stampOf returns the stamp of a file, or the zero stamp if it cannot be read
+func stampOf(filePath string) fileStamp {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// changedSince reports whether the file no longer matches stamp
+func changedSince(filePath string, stamp fileStamp) bool {
+	current := stampOf(filePath)
+	return !current.modTime.Equal(stamp.modTime) || current.size != stamp.size
+}
+
+// backupFile copies a file before it is rewritten in a newer format
+func backupFile(filePath string, backupPath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", backupPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package avoidlist
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wallet-guesser/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultHistoryLimit is how many snapshots are kept before the oldest are pruned
+	DefaultHistoryLimit = 30
+
+	// snapshotTimeFormat names snapshots so they sort by the time they were taken
+	snapshotTimeFormat = "20060102T150405.000000Z"
+)
+
+// Snapshot is a copy of the avoid list file taken before it was changed
+type Snapshot struct {
+	Name        string    `json:"name"`
+	TakenAt     time.Time `json:"takenAt"`
+	Entries     int       `json:"entries"`
+	LastUpdated time.Time `json:"lastUpdated"`
+}
+
+// EntryChange is an entry whose type or category changed
+type EntryChange struct {
+	Before domain.AvoidListEntry `json:"before"`
+	After  domain.AvoidListEntry `json:"after"`
+}
+
+// Diff lists the entries added, removed and changed between two versions of the list
+type Diff struct {
+	Added   []domain.AvoidListEntry `json:"added"`
+	Removed []domain.AvoidListEntry `json:"removed"`
+	Changed []EntryChange           `json:"changed"`
+}
+
+// Empty reports whether the two versions hold the same entries
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffEntries compares two versions of the list. Entries are ordered by key.
+func DiffEntries(before, after map[string]domain.AvoidListEntry) Diff {
+	var diff Diff
+	for _, entry := range sortedEntries(after) {
+		previous, found := before[entry.Key()]
+		if !found {
+			diff.Added = append(diff.Added, entry)
+		} else if previous != entry {
+			diff.Changed = append(diff.Changed, EntryChange{Before: previous, After: entry})
+		}
+	}
+	for _, entry := range sortedEntries(before) {
+		if _, found := after[entry.Key()]; !found {
+			diff.Removed = append(diff.Removed, entry)
+		}
+	}
+	return diff
+}
+
+// WithHistoryDir sets the directory snapshots are kept in. By default it sits
+// next to the avoid list file, e.g. data/avoidlist_history.
+func WithHistoryDir(dir string) Option {
+	return func(s *Service) {
+		s.historyDir = dir
+	}
+}
+
+// WithHistoryLimit sets how many snapshots are kept. Zero keeps them all.
+func WithHistoryLimit(limit int) Option {
+	return func(s *Service) {
+		s.historyLimit = limit
+	}
+}
+
+// WithDryRun makes every change in memory only: nothing is written to disk
+func WithDryRun(dryRun bool) Option {
+	return func(s *Service) {
+		s.dryRun = dryRun
+	}
+}
+
+// HistoryDir returns the directory snapshots are kept in
+func (s *Service) HistoryDir() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.historyDirLocked()
+}
+
+// historyDirLocked returns the history directory. The caller must hold the mutex.
+func (s *Service) historyDirLocked() string {
+	if s.historyDir != "" {
+		return s.historyDir
+	}
+	return strings.TrimSuffix(s.filePath, filepath.Ext(s.filePath)) + "_history"
+}
+
+// snapshotPrefix is the start of every snapshot name, taken from the avoid list file name
+func snapshotPrefix(filePath string) string {
+	base := filepath.Base(filePath)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "-"
+}
+
+// Entries returns a copy of the entries keyed by address or prefix
+func (s *Service) Entries() map[string]domain.AvoidListEntry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	entries := make(map[string]domain.AvoidListEntry, len(s.entries))
+	for key, entry := range s.entries {
+		entries[key] = entry
+	}
+	return entries
+}
+
+// Snapshot copies the avoid list file into the history directory and prunes
+// the oldest snapshots. Nothing is copied when there is no file yet or it is
+// unchanged since the latest snapshot, in which case the name is empty.
+func (s *Service) Snapshot() (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.snapshotLocked()
+}
+
+// snapshotLocked takes a snapshot. The caller must hold the mutex.
+func (s *Service) snapshotLocked() (string, error) {
+	if s.dryRun {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(s.filePath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read avoid list file: %w", err)
+	}
+
+	dir := s.historyDirLocked()
+	names, err := snapshotNames(dir, s.filePath)
+	if err != nil {
+		return "", err
+	}
+	if len(names) > 0 {
+		latest, err := os.ReadFile(filepath.Join(dir, names[len(names)-1]))
+		if err == nil && bytes.Equal(latest, data) {
+			return "", nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create avoid list history directory: %w", err)
+	}
+	name := snapshotPrefix(s.filePath) + time.Now().UTC().Format(snapshotTimeFormat) + filepath.Ext(s.filePath)
+	if err := writeNewFile(filepath.Join(dir, name), data); err != nil {
+		return "", fmt.Errorf("failed to write avoid list snapshot: %w", err)
+	}
+	log.Infof("Saved avoid list snapshot %s", name)
+
+	names = append(names, name)
+	if s.historyLimit > 0 && len(names) > s.historyLimit {
+		for _, old := range names[:len(names)-s.historyLimit] {
+			if old == name {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, old)); err != nil {
+				log.Warnf("Failed to prune avoid list snapshot %s: %v", old, err)
+			}
+		}
+	}
+	return name, nil
+}
+
+// writeNewFile writes a file that must not exist yet, so a snapshot is never overwritten
+func writeNewFile(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// snapshotNames lists the snapshots of an avoid list file, oldest first
+func snapshotNames(dir string, filePath string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avoid list history: %w", err)
+	}
+
+	prefix := snapshotPrefix(filePath)
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasPrefix(file.Name(), prefix) && strings.HasSuffix(file.Name(), filepath.Ext(filePath)) {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Snapshots lists the snapshots in the history directory, oldest first
+func (s *Service) Snapshots() ([]Snapshot, error) {
+	s.mutex.RLock()
+	dir := s.historyDirLocked()
+	filePath := s.filePath
+	s.mutex.RUnlock()
+
+	names, err := snapshotNames(dir, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := snapshotPrefix(filePath)
+	snapshots := make([]Snapshot, 0, len(names))
+	for _, name := range names {
+		snapshot := Snapshot{Name: name}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), filepath.Ext(filePath))
+		snapshot.TakenAt, _ = time.Parse(snapshotTimeFormat, stamp)
+		if fileData, err := readListFile(filepath.Join(dir, name)); err == nil {
+			snapshot.Entries = len(fileData.Entries)
+			snapshot.LastUpdated = fileData.LastUpdated
+		} else {
+			log.Warnf("Could not read avoid list snapshot %s: %v", name, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// latestDifferentSnapshot returns the newest snapshot that differs from the
+// current file. The caller must hold the mutex.
+func (s *Service) latestDifferentSnapshot(dir string) (string, error) {
+	names, err := snapshotNames(dir, s.filePath)
+	if err != nil {
+		return "", err
+	}
+	current, _ := os.ReadFile(s.filePath)
+	for i := len(names) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(filepath.Join(dir, names[i]))
+		if err == nil && !bytes.Equal(data, current) {
+			return names[i], nil
+		}
+	}
+	return "", fmt.Errorf("no avoid list snapshots in %s that differ from the current list", dir)
+}
+
+// Rollback restores the list from a snapshot, or the latest one that differs
+// from the current list when name is empty, and saves it. The current file is
+// snapshotted first, so a rollback can itself be rolled back. It returns the
+// name of the restored snapshot.
+func (s *Service) Rollback(name string) (string, error) {
+	s.mutex.Lock()
+	name, err := s.rollbackLocked(name)
+	s.mutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return name, s.saveToFile()
+}
+
+// rollbackLocked swaps in the entries of a snapshot without saving them. The caller must hold the mutex.
+func (s *Service) rollbackLocked(name string) (string, error) {
+	dir := s.historyDirLocked()
+	if name == "" {
+		latest, err := s.latestDifferentSnapshot(dir)
+		if err != nil {
+			return "", err
+		}
+		name = latest
+	}
+	if name != filepath.Base(name) {
+		return "", fmt.Errorf("snapshot name %q must not contain a directory", name)
+	}
+
+	fileData, err := readListFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	entries, dropped, err := fileData.buildEntries()
+	if err != nil {
+		return "", err
+	}
+	if dropped > 0 {
+		log.Warnf("Dropped %d invalid entries from snapshot %s", dropped, name)
+	}
+
+	if _, err := s.snapshotLocked(); err != nil {
+		return "", fmt.Errorf("failed to snapshot the current list before rolling back: %w", err)
+	}
+
+	s.setEntries(entries)
+	s.lastUpdated = fileData.LastUpdated
+	return name, nil
+}
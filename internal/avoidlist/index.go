@@ -0,0 +1,132 @@
+package avoidlist
+
+import (
+	"fmt"
+	"sort"
+
+	"wallet-guesser/internal/domain"
+)
+
+const (
+	// MinPrefixLength is the shortest prefix an entry may have
+	MinPrefixLength = 8
+	// MinAddressLength is the length of the shortest base58 Solana address.
+	// Keys at least this long are treated as full addresses and matched exactly.
+	MinAddressLength = 32
+)
+
+// index answers lookups against the avoid list. Full addresses sit in an exact
+// set; prefixes are bucketed by length so an address is checked against each
+// distinct prefix length, longest first, rather than against every prefix.
+type index[T any] struct {
+	exact         map[string]T
+	prefixes      map[string]T
+	prefixLengths []int
+	lengthCounts  map[int]int // number of prefixes of each length
+}
+
+// newIndex builds an index over items keyed by address or prefix. Keys of
+// address length are matched exactly, shorter ones as prefixes.
+func newIndex[T any](items map[string]T) *index[T] {
+	idx := &index[T]{
+		exact:        make(map[string]T),
+		prefixes:     make(map[string]T),
+		lengthCounts: make(map[int]int),
+	}
+
+	for key, item := range items {
+		if len(key) >= MinAddressLength {
+			idx.exact[key] = item
+			continue
+		}
+		idx.prefixes[key] = item
+		idx.lengthCounts[len(key)]++
+	}
+
+	for length := range idx.lengthCounts {
+		idx.prefixLengths = append(idx.prefixLengths, length)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(idx.prefixLengths)))
+	return idx
+}
+
+// set adds or replaces the item stored under key, without rebuilding the index
+func (idx *index[T]) set(key string, item T) {
+	if len(key) >= MinAddressLength {
+		idx.exact[key] = item
+		return
+	}
+	if _, found := idx.prefixes[key]; !found {
+		if idx.lengthCounts[len(key)] == 0 {
+			idx.prefixLengths = append(idx.prefixLengths, len(key))
+			sort.Sort(sort.Reverse(sort.IntSlice(idx.prefixLengths)))
+		}
+		idx.lengthCounts[len(key)]++
+	}
+	idx.prefixes[key] = item
+}
+
+// remove deletes the item stored under key, without rebuilding the index
+func (idx *index[T]) remove(key string) {
+	if len(key) >= MinAddressLength {
+		delete(idx.exact, key)
+		return
+	}
+	if _, found := idx.prefixes[key]; !found {
+		return
+	}
+	delete(idx.prefixes, key)
+
+	idx.lengthCounts[len(key)]--
+	if idx.lengthCounts[len(key)] > 0 {
+		return
+	}
+	delete(idx.lengthCounts, len(key))
+	for i, length := range idx.prefixLengths {
+		if length == len(key) {
+			idx.prefixLengths = append(idx.prefixLengths[:i], idx.prefixLengths[i+1:]...)
+			break
+		}
+	}
+}
+
+// lookup returns the item matching an address: an exact item if there is one,
+// otherwise the item with the longest matching prefix
+func (idx *index[T]) lookup(address string) (T, bool) {
+	if item, ok := idx.exact[address]; ok {
+		return item, true
+	}
+	for _, length := range idx.prefixLengths {
+		if length > len(address) {
+			continue
+		}
+		if item, ok := idx.prefixes[address[:length]]; ok {
+			return item, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// normalizeEntry checks an entry is a token or wallet and stores its key as an
+// exact address when it is of address length, or as a prefix long enough to be useful
+func normalizeEntry(entry domain.AvoidListEntry) (domain.AvoidListEntry, error) {
+	key := entry.Key()
+	if len(key) < MinPrefixLength {
+		return entry, fmt.Errorf("prefix must be at least %d characters", MinPrefixLength)
+	}
+	if entry.Type != "t" && entry.Type != "w" {
+		return entry, fmt.Errorf("type must be \"t\" (token) or \"w\" (wallet)")
+	}
+	if entry.Category != "" && !entry.Category.Valid() {
+		return entry, fmt.Errorf("category %q must be lower-case snake_case", entry.Category)
+	}
+
+	entry.Address, entry.Prefix = "", ""
+	if len(key) >= MinAddressLength {
+		entry.Address = key
+	} else {
+		entry.Prefix = key
+	}
+	return entry, nil
+}
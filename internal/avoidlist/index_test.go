@@ -0,0 +1,117 @@
+package avoidlist
+
+import (
+	"testing"
+
+	"wallet-guesser/internal/domain"
+)
+
+func TestIndexLookup(t *testing.T) {
+	idx := newIndex(map[string]string{
+		"7xKXtg2C":         "short prefix",
+		"7xKXtg2CW87d97TX": "long prefix",
+		"7xKXtg2CW87":      "middle prefix",
+		testWallet:         "exact wallet",
+		testAddress[:10]:   "prefix of an exact address",
+		testAddress:        "exact address",
+	})
+
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		found   bool
+	}{
+		{"exact address beats a matching prefix", testAddress, "exact address", true},
+		{"exact wallet", testWallet, "exact wallet", true},
+		{"longest prefix wins", "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU", "long prefix", true},
+		{"middle prefix", "7xKXtg2CW87xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx", "middle prefix", true},
+		{"shortest prefix", "7xKXtg2Cxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx", "short prefix", true},
+		{"address shorter than a prefix", "7xKXtg2", "", false},
+		{"prefix of an address only matches other addresses", testAddress[:10] + "zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz", "prefix of an exact address", true},
+		{"exact address is not a prefix", testWallet + "x", "", false},
+		{"no match", "3NZ9JMVBmGAqocybic2c7LQCJScmgsAZ6vQqTDzcqmJh", "", false},
+		{"prefixes are case sensitive", "7XKXTG2Cxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := idx.lookup(test.address)
+			if found != test.found || got != test.want {
+				t.Fatalf("lookup(%s) = %q, %v, want %q, %v", test.address, got, found, test.want, test.found)
+			}
+		})
+	}
+}
+
+func TestIndexEmpty(t *testing.T) {
+	idx := newIndex(map[string]domain.AvoidListEntry{})
+	if _, found := idx.lookup(testAddress); found {
+		t.Fatal("empty index matched an address")
+	}
+}
+
+func TestNormalizeEntry(t *testing.T) {
+	tests := []struct {
+		name        string
+		entry       domain.AvoidListEntry
+		wantPrefix  string
+		wantAddress string
+		wantErr     bool
+	}{
+		{"prefix", domain.AvoidListEntry{Prefix: "7xKXtg2C", Type: "w"}, "7xKXtg2C", "", false},
+		{"address given as a prefix", domain.AvoidListEntry{Prefix: testAddress, Type: "t"}, "", testAddress, false},
+		{"prefix given as an address", domain.AvoidListEntry{Address: "7xKXtg2C", Type: "t"}, "7xKXtg2C", "", false},
+		{"too short", domain.AvoidListEntry{Prefix: "7xKXtg2", Type: "t"}, "", "", true},
+		{"missing type", domain.AvoidListEntry{Prefix: "7xKXtg2C"}, "", "", true},
+		{"bad category", domain.AvoidListEntry{Prefix: "7xKXtg2C", Type: "t", Category: "Not Valid"}, "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := normalizeEntry(test.entry)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("error = %v, want error %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if entry.Prefix != test.wantPrefix || entry.Address != test.wantAddress {
+				t.Fatalf("normalized to prefix %q and address %q", entry.Prefix, entry.Address)
+			}
+		})
+	}
+}
+
+func TestIndexSetAndRemove(t *testing.T) {
+	idx := newIndex(map[string]string{"7xKXtg2C": "short prefix"})
+
+	idx.set("7xKXtg2CW87", "middle prefix")
+	idx.set(testAddress, "exact address")
+	idx.set("7xKXtg2C", "short prefix, replaced")
+	if got, _ := idx.lookup("7xKXtg2CW87xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"); got != "middle prefix" {
+		t.Fatalf("after set, lookup = %q, want the middle prefix", got)
+	}
+	if got, _ := idx.lookup(testAddress); got != "exact address" {
+		t.Fatalf("after set, lookup = %q, want the exact address", got)
+	}
+	if got, _ := idx.lookup("7xKXtg2Cxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"); got != "short prefix, replaced" {
+		t.Fatalf("after replacing, lookup = %q", got)
+	}
+	if len(idx.prefixLengths) != 2 || idx.prefixLengths[0] != 11 || idx.prefixLengths[1] != 8 {
+		t.Fatalf("prefix lengths = %v, want [11 8]", idx.prefixLengths)
+	}
+
+	idx.remove("7xKXtg2CW87")
+	idx.remove(testAddress)
+	idx.remove("7xKXtg2CW87d97TX") // not in the index
+	if got, _ := idx.lookup("7xKXtg2CW87xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"); got != "short prefix, replaced" {
+		t.Fatalf("after remove, lookup = %q, want the short prefix", got)
+	}
+	if len(idx.prefixLengths) != 1 || idx.prefixLengths[0] != 8 {
+		t.Fatalf("prefix lengths = %v, want [8]", idx.prefixLengths)
+	}
+
+	idx.remove("7xKXtg2C")
+	if _, found := idx.lookup(testAddress); found || len(idx.prefixLengths) != 0 {
+		t.Fatalf("index not empty after removing everything: %v", idx.prefixLengths)
+	}
+}
@@ -0,0 +1,238 @@
+package avoidlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"wallet-guesser/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultOverridesPath is the default path to the avoid list overrides file
+const DefaultOverridesPath = "data/avoidlist_overrides.json"
+
+// overridesFile is the persisted form of the overrides
+type overridesFile struct {
+	Overrides []domain.AvoidListOverride `json:"overrides"`
+}
+
+// WithOverridesPath sets the file manual overrides are loaded from and saved to
+func WithOverridesPath(filePath string) Option {
+	return func(s *Service) {
+		if filePath != "" {
+			s.overridesPath = filePath
+		}
+	}
+}
+
+// OverridesPath returns the file the overrides are loaded from and saved to
+func (s *Service) OverridesPath() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.overridesPath
+}
+
+// LoadOverrides loads the manual overrides from their file. A missing file
+// means no overrides; a bad file leaves the current overrides in place.
+func (s *Service) LoadOverrides() error {
+	filePath := s.OverridesPath()
+
+	stamp := stampOf(filePath)
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		log.Debugf("Avoid list overrides file not found: %s", filePath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read avoid list overrides: %w", err)
+	}
+
+	var fileData overridesFile
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		return fmt.Errorf("failed to unmarshal avoid list overrides: %w", err)
+	}
+
+	overrides := make(map[string]domain.AvoidListOverride, len(fileData.Overrides))
+	for _, override := range fileData.Overrides {
+		override, err := normalizeOverride(override)
+		if err != nil {
+			return fmt.Errorf("invalid override in %s: %w", filePath, err)
+		}
+		overrides[override.Key()] = override
+	}
+
+	count := len(overrides)
+	s.mutex.Lock()
+	s.setOverrides(overrides)
+	s.overridesStamp = stamp
+	s.mutex.Unlock()
+
+	log.Infof("Loaded %d avoid list overrides from %s", count, filePath)
+	return nil
+}
+
+// setOverrides replaces the overrides and rebuilds their index. The caller must hold the mutex.
+func (s *Service) setOverrides(overrides map[string]domain.AvoidListOverride) {
+	s.overrides = overrides
+	s.overrideIndex = newIndex(overrides)
+}
+
+// saveOverrides atomically writes a copy of the overrides to their file. The
+// caller must not hold the mutex, so lookups are not blocked while it writes.
+func (s *Service) saveOverrides() error {
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	s.mutex.RLock()
+	overrides := make([]domain.AvoidListOverride, 0, len(s.overrides))
+	for _, override := range s.overrides {
+		overrides = append(overrides, override)
+	}
+	filePath := s.overridesPath
+	s.mutex.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for avoid list overrides: %w", err)
+	}
+
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Key() < overrides[j].Key() })
+
+	// Indented, since the file is meant to be read and reviewed by people
+	data, err := json.MarshalIndent(overridesFile{Overrides: overrides}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal avoid list overrides: %w", err)
+	}
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write avoid list overrides: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace avoid list overrides: %w", err)
+	}
+
+	stamp := stampOf(filePath)
+	s.mutex.Lock()
+	s.overridesStamp = stamp
+	s.mutex.Unlock()
+	return nil
+}
+
+// OverridesChanged reports whether the overrides file differs from the version
+// the service last loaded or saved, so a watcher can skip the service's own writes
+func (s *Service) OverridesChanged() bool {
+	s.mutex.RLock()
+	filePath, stamp := s.overridesPath, s.overridesStamp
+	s.mutex.RUnlock()
+
+	return changedSince(filePath, stamp)
+}
+
+// SetOverride adds or replaces the override for an address or prefix and saves
+// the overrides. Only the changed key is re-indexed.
+func (s *Service) SetOverride(override domain.AvoidListOverride) error {
+	override, err := normalizeOverride(override)
+	if err != nil {
+		return err
+	}
+	if override.CreatedAt.IsZero() {
+		override.CreatedAt = time.Now()
+	}
+
+	s.mutex.Lock()
+	s.overrides[override.Key()] = override
+	s.overrideIndex.set(override.Key(), override)
+	s.mutex.Unlock()
+
+	return s.saveOverrides()
+}
+
+// RemoveOverride removes the override stored under an address or prefix and
+// saves the overrides, reporting whether it existed
+func (s *Service) RemoveOverride(key string) (bool, error) {
+	s.mutex.Lock()
+	if _, ok := s.overrides[key]; !ok {
+		s.mutex.Unlock()
+		return false, nil
+	}
+	delete(s.overrides, key)
+	s.overrideIndex.remove(key)
+	s.mutex.Unlock()
+
+	return true, s.saveOverrides()
+}
+
+// Overrides returns every override, ordered by address or prefix
+func (s *Service) Overrides() []domain.AvoidListOverride {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	overrides := make([]domain.AvoidListOverride, 0, len(s.overrides))
+	for _, override := range s.overrides {
+		overrides = append(overrides, override)
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Key() < overrides[j].Key() })
+	return overrides
+}
+
+// GetOverride returns the override matching an address, if any
+func (s *Service) GetOverride(address string) (domain.AvoidListOverride, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.overrideIndex.lookup(address)
+}
+
+// overrideStats counts the overrides by action. The caller must hold the mutex.
+func (s *Service) overrideStats() map[string]interface{} {
+	deny, allow := 0, 0
+	for _, override := range s.overrides {
+		if override.Action == domain.OverrideDeny {
+			deny++
+		} else {
+			allow++
+		}
+	}
+	return map[string]interface{}{
+		"deny":  deny,
+		"allow": allow,
+	}
+}
+
+// normalizeOverride checks an override and stores its key as an address or prefix
+func normalizeOverride(override domain.AvoidListOverride) (domain.AvoidListOverride, error) {
+	key := override.Key()
+	if len(key) < MinPrefixLength {
+		return override, fmt.Errorf("prefix must be at least %d characters", MinPrefixLength)
+	}
+	if override.Action != domain.OverrideDeny && override.Action != domain.OverrideAllow {
+		return override, fmt.Errorf("action must be %q or %q", domain.OverrideDeny, domain.OverrideAllow)
+	}
+	if override.Type != "" && override.Type != "t" && override.Type != "w" {
+		return override, fmt.Errorf("type must be \"t\" (token) or \"w\" (wallet)")
+	}
+	if override.Category != "" && !override.Category.Valid() {
+		return override, fmt.Errorf("category %q must be lower-case snake_case", override.Category)
+	}
+	if strings.TrimSpace(override.Reason) == "" {
+		return override, fmt.Errorf("a reason is required")
+	}
+	if strings.TrimSpace(override.Author) == "" {
+		return override, fmt.Errorf("an author is required")
+	}
+
+	override.Address, override.Prefix = "", ""
+	if len(key) >= MinAddressLength {
+		override.Address = key
+	} else {
+		override.Prefix = key
+	}
+	return override, nil
+}
@@ -0,0 +1,87 @@
+package avoidlist
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// refreshRetryDelay is how soon a failed scheduled refresh is retried, unless
+// the refresh interval is shorter
+const refreshRetryDelay = 15 * time.Minute
+
+// Run refreshes the avoid list from Dune every refresh interval until ctx is
+// done. The first refresh happens once the loaded list is an interval old, so a
+// restart does not re-fetch a fresh list. A failed refresh keeps the current
+// entries and is retried sooner than the interval.
+func (s *Service) Run(ctx context.Context) {
+	if s.refreshInterval <= 0 {
+		log.Info("Scheduled avoid list refresh disabled")
+		return
+	}
+	if s.apiKey == "" {
+		log.Warn("Scheduled avoid list refresh disabled, DUNE_API_KEY is not set")
+		return
+	}
+
+	s.mutex.RLock()
+	delay := s.refreshInterval - time.Since(s.lastUpdated)
+	s.mutex.RUnlock()
+	if delay < 0 {
+		delay = 0
+	}
+
+	log.Infof("Refreshing the avoid list every %s", s.refreshInterval)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		s.setNextRefresh(time.Now().Add(delay))
+
+		select {
+		case <-ctx.Done():
+			s.setNextRefresh(time.Time{})
+			return
+		case <-timer.C:
+		}
+
+		delay = s.refreshInterval
+		if err := s.refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				s.setNextRefresh(time.Time{})
+				return
+			}
+			delay = min(refreshRetryDelay, s.refreshInterval)
+			log.Errorf("Scheduled avoid list refresh failed, keeping the current list and retrying in %s: %v", delay, err)
+		}
+		timer.Reset(delay)
+	}
+}
+
+// setNextRefresh records when the next scheduled refresh is due
+func (s *Service) setNextRefresh(at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextRefreshAt = at
+}
+
+// refreshStats describes the scheduled refreshes. The caller must hold the mutex.
+func (s *Service) refreshStats() map[string]interface{} {
+	return map[string]interface{}{
+		"interval":    s.refreshInterval.String(),
+		"lastSuccess": formatTime(s.lastRefreshAt),
+		"lastError":   s.lastRefreshError,
+		"lastErrorAt": formatTime(s.lastErrorAt),
+		"nextRefresh": formatTime(s.nextRefreshAt),
+	}
+}
+
+// formatTime formats a time as RFC 3339, or an empty string when it is unset
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -1,177 +1,368 @@
 package avoidlist
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/metrics"
 
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	// DefaultAvoidListPath is the default path to the avoid list file
-	DefaultAvoidListPath = "data/avoidlist.json"
+	DefaultAvoidListPath = "data/avoidlist.bin"
+	// DefaultDuneQueryID is the Dune query that returns the avoid list
+	DefaultDuneQueryID = 4966121
+	// DefaultRefreshInterval is how often Run refreshes the avoid list from Dune
+	DefaultRefreshInterval = 24 * time.Hour
+
+	// duneTimeout bounds a single request to the Dune API
+	duneTimeout = 2 * time.Minute
 )
 
+// ErrUpdatedRecently is returned by UpdateAvoidList when the list was updated
+// within the past day. ForceUpdateAvoidList ignores the limit.
+var ErrUpdatedRecently = errors.New("avoid list was already updated within the past day")
+
 // Service implements the AvoidListService interface
 type Service struct {
-	apiEndpoint string
 	apiKey      string
 	filePath    string
 	entries     map[string]domain.AvoidListEntry
+	index       *index[domain.AvoidListEntry]
 	lastUpdated time.Time
 	mutex       sync.RWMutex
+	// saveMutex serialises writes of the list and overrides files, which
+	// happen outside mutex so lookups are never blocked on disk
+	saveMutex sync.Mutex
+	// listStamp and overridesStamp identify the files as last loaded or saved,
+	// so reloads triggered by the service's own writes can be skipped
+	listStamp      fileStamp
+	overridesStamp fileStamp
+
+	// Dune query the list is fetched from, reading its latest results or running it afresh
+	dune         *DuneClient
+	queryID      int
+	executeQuery bool
+
+	// Snapshots taken before the file is changed, and dry runs that never write it
+	historyDir   string
+	historyLimit int
+	dryRun       bool
+
+	// Manual overrides, consulted before the entries
+	overridesPath string
+	overrides     map[string]domain.AvoidListOverride
+	overrideIndex *index[domain.AvoidListOverride]
+
+	// Refresh bookkeeping, reported in the stats
+	refreshInterval  time.Duration
+	lastRefreshAt    time.Time
+	lastRefreshError string
+	lastErrorAt      time.Time
+	nextRefreshAt    time.Time
+}
+
+// Option is a functional option for configuring the avoid list service
+type Option func(*Service)
+
+// WithDuneQueryID sets the Dune query the avoid list is fetched from. The
+// query must return zip_prefix and zip_types columns.
+func WithDuneQueryID(queryID int) Option {
+	return func(s *Service) {
+		s.queryID = queryID
+	}
+}
+
+// WithDuneExecution makes refreshes run the Dune query afresh and wait for it,
+// rather than read the results of its last execution. Executions use Dune credits.
+func WithDuneExecution(execute bool) Option {
+	return func(s *Service) {
+		s.executeQuery = execute
+	}
+}
+
+// WithDuneClient replaces the Dune client, for example to tune its retries
+func WithDuneClient(client *DuneClient) Option {
+	return func(s *Service) {
+		s.dune = client
+	}
+}
+
+// WithRefreshInterval sets how often Run refreshes the avoid list from Dune.
+// Zero disables scheduled refreshes.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.refreshInterval = interval
+	}
 }
 
 // NewService creates a new AvoidListService
-func NewService(apiKey string, filePath string) *Service {
+func NewService(apiKey string, filePath string, options ...Option) *Service {
 	if filePath == "" {
 		filePath = DefaultAvoidListPath
 	}
 
-	return &Service{
-		apiEndpoint: "https://api.dune.com/api/v1/query/4966121/results",
-		apiKey:      apiKey,
-		filePath:    filePath,
-		entries:     make(map[string]domain.AvoidListEntry),
+	s := &Service{
+		apiKey:   apiKey,
+		filePath: filePath,
+		entries:  make(map[string]domain.AvoidListEntry),
+		index:    newIndex[domain.AvoidListEntry](nil),
+
+		dune:    NewDuneClient(apiKey),
+		queryID: DefaultDuneQueryID,
+
+		historyLimit: DefaultHistoryLimit,
+
+		overridesPath: DefaultOverridesPath,
+		overrides:     make(map[string]domain.AvoidListOverride),
+		overrideIndex: newIndex[domain.AvoidListOverride](nil),
+
+		refreshInterval: DefaultRefreshInterval,
 	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
-// LoadFromFile loads the avoid list from a file
-func (s *Service) LoadFromFile() error {
+// FilePath returns the file the avoid list is loaded from and saved to
+func (s *Service) FilePath() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.filePath
+}
+
+// SetFilePath changes the file the avoid list is loaded from and saved to.
+// Call LoadFromFile afterwards to pick up its entries.
+func (s *Service) SetFilePath(filePath string) {
+	if filePath == "" {
+		filePath = DefaultAvoidListPath
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	s.filePath = filePath
+}
+
+// LoadFromFile loads the avoid list from a file, binary or JSON. The file is
+// read before the entries are swapped in, so lookups are never blocked on disk
+// and a bad file leaves the current entries in place. A binary list that does
+// not exist yet is imported from the JSON file next to it, if there is one.
+func (s *Service) LoadFromFile() error {
+	filePath := s.FilePath()
+	readPath := filePath
+
 	// Check if file exists
-	if _, err := os.Stat(s.filePath); os.IsNotExist(err) {
-		log.Infof("Avoid list file not found: %s", s.filePath)
-		return nil
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		if !isBinaryPath(filePath) {
+			log.Infof("Avoid list file not found: %s", filePath)
+			return nil
+		}
+		readPath = jsonSibling(filePath)
+		if _, err := os.Stat(readPath); os.IsNotExist(err) {
+			log.Infof("Avoid list file not found: %s", filePath)
+			return nil
+		}
 	}
 
-	file, err := os.Open(s.filePath)
+	stamp := stampOf(readPath)
+	fileData, err := readListFile(readPath)
 	if err != nil {
-		return fmt.Errorf("failed to open avoid list file: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	// Build the new map and index, then swap them in
+	entries, dropped, err := fileData.buildEntries()
 	if err != nil {
-		return fmt.Errorf("failed to read avoid list file: %w", err)
+		return err
+	}
+	if dropped > 0 {
+		log.Warnf("Dropped %d invalid avoid list entries", dropped)
 	}
 
-	var fileData struct {
-		Entries     []domain.AvoidListEntry `json:"entries"`
-		LastUpdated time.Time               `json:"lastUpdated"`
+	count := len(entries)
+	s.mutex.Lock()
+	s.setEntries(entries)
+	s.lastUpdated = fileData.LastUpdated
+	moved := s.filePath != filePath
+	if readPath == filePath && !moved {
+		s.listStamp = stamp
 	}
+	s.mutex.Unlock()
+
+	log.Infof("Loaded %d avoid list entries from %s, last updated at %s", count, readPath, fileData.LastUpdated.Format(time.RFC3339))
 
-	if err := json.Unmarshal(data, &fileData); err != nil {
-		return fmt.Errorf("failed to unmarshal avoid list data: %w", err)
+	if moved || s.dryRun {
+		return nil
 	}
 
-	// Populate the in-memory map
-	s.entries = make(map[string]domain.AvoidListEntry, len(fileData.Entries))
-	for _, entry := range fileData.Entries {
-		s.entries[entry.Prefix] = entry
+	// Save a JSON list found in place of the binary one in the binary format, leaving the JSON file alone
+	if readPath != filePath {
+		if err := s.saveToFile(); err != nil {
+			return fmt.Errorf("failed to import avoid list from %s: %w", readPath, err)
+		}
+		log.Infof("Imported avoid list from %s into %s", readPath, filePath)
+		return nil
 	}
-	s.lastUpdated = fileData.LastUpdated
 
-	log.Infof("Loaded %d avoid list entries, last updated at %s", len(s.entries), s.lastUpdated.Format(time.RFC3339))
+	// Rewrite older files in the current format, keeping the original alongside
+	if fileData.Version < fileFormatVersion {
+		backupPath := fmt.Sprintf("%s.v%d.bak", filePath, max(fileData.Version, 1))
+		if err := backupFile(filePath, backupPath); err != nil {
+			return fmt.Errorf("failed to back up avoid list before migrating: %w", err)
+		}
+		if err := s.saveToFile(); err != nil {
+			return fmt.Errorf("failed to migrate avoid list: %w", err)
+		}
+		log.Infof("Migrated avoid list file to version %d, the original is kept at %s", fileFormatVersion, backupPath)
+	}
 	return nil
 }
 
-// SaveToFile saves the avoid list to a file
-func (s *Service) saveToFile() error {
+// FileChanged reports whether the avoid list file differs from the version the
+// service last loaded or saved, so a watcher can skip the service's own writes
+func (s *Service) FileChanged() bool {
+	s.mutex.RLock()
+	filePath, stamp := s.filePath, s.listStamp
+	s.mutex.RUnlock()
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(s.filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory for avoid list: %w", err)
-	}
+	return changedSince(filePath, stamp)
+}
+
+// setEntries replaces the entries and rebuilds the lookup index. The caller must hold the mutex.
+func (s *Service) setEntries(entries map[string]domain.AvoidListEntry) {
+	s.entries = entries
+	s.index = newIndex(entries)
+}
+
+// saveToFile saves a copy of the avoid list to its file. In a dry run nothing
+// is written. The caller must not hold the mutex: only the copy is taken under
+// it, and saves are serialised so the file always ends up with the latest list.
+func (s *Service) saveToFile() error {
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
 
-	// Convert map to slice for JSON serialization
+	s.mutex.RLock()
 	entries := make([]domain.AvoidListEntry, 0, len(s.entries))
 	for _, entry := range s.entries {
 		entries = append(entries, entry)
 	}
+	filePath, lastUpdated := s.filePath, s.lastUpdated
+	s.mutex.RUnlock()
 
-	fileData := struct {
-		Entries     []domain.AvoidListEntry `json:"entries"`
-		LastUpdated time.Time               `json:"lastUpdated"`
-	}{
-		Entries:     entries,
-		LastUpdated: s.lastUpdated,
+	if s.dryRun {
+		log.Infof("Dry run, not saving %d avoid list entries to %s", len(entries), filePath)
+		return nil
 	}
 
-	data, err := json.Marshal(fileData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal avoid list data: %w", err)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key() < entries[j].Key()
+	})
+	if err := writeListFile(filePath, entries, lastUpdated); err != nil {
+		return err
 	}
-
-	// Write to file
-	if err := os.WriteFile(s.filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write avoid list file: %w", err)
+	stamp := stampOf(filePath)
+	s.mutex.Lock()
+	if s.filePath == filePath {
+		s.listStamp = stamp
 	}
+	s.mutex.Unlock()
 
-	log.Infof("Saved %d avoid list entries to %s", len(s.entries), s.filePath)
+	log.Infof("Saved %d avoid list entries to %s", len(entries), filePath)
 	return nil
 }
 
-// UpdateAvoidList updates the avoid list from the remote API
-func (s *Service) UpdateAvoidList() error {
-	if s.apiKey == "" {
-		return fmt.Errorf("API key not provided for avoid list")
-	}
+// Export writes the avoid list to another file, in the binary format when its
+// name ends in .bin and as JSON otherwise
+func (s *Service) Export(filePath string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	// if updated in past day, warn the user
-	if s.lastUpdated.After(time.Now().Add(-time.Hour * 24)) {
-		log.Warnf("Avoid list is already updated within the past day: %s", s.lastUpdated)
-		return nil
-	}
+	return len(s.entries), writeListFile(filePath, sortedEntries(s.entries), s.lastUpdated)
+}
 
-	url := fmt.Sprintf("%s?api_key=%s", s.apiEndpoint, s.apiKey)
-	resp, err := http.Get(url)
+// Import replaces the avoid list with the entries of another file, binary or
+// JSON, and saves it. Invalid entries are dropped and counted.
+func (s *Service) Import(filePath string) (int, int, error) {
+	fileData, err := readListFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to request avoid list data: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned non-200 status: %d", resp.StatusCode)
+		return 0, 0, err
 	}
-
-	data, err := io.ReadAll(resp.Body)
+	entries, dropped, err := fileData.buildEntries()
 	if err != nil {
-		return fmt.Errorf("failed to read API response: %w", err)
+		return 0, 0, err
 	}
 
-	var response domain.AvoidListResponse
-	if err := json.Unmarshal(data, &response); err != nil {
-		return fmt.Errorf("failed to unmarshal API response: %w", err)
+	count := len(entries)
+	s.mutex.Lock()
+	s.setEntries(entries)
+	s.lastUpdated = fileData.LastUpdated
+	s.mutex.Unlock()
+
+	return count, dropped, s.saveToFile()
+}
+
+// UpdateAvoidList updates the avoid list from the remote API, unless it was
+// updated within the past day, in which case it returns ErrUpdatedRecently
+func (s *Service) UpdateAvoidList() error {
+	s.mutex.RLock()
+	lastUpdated := s.lastUpdated
+	s.mutex.RUnlock()
+	if lastUpdated.After(time.Now().Add(-time.Hour * 24)) {
+		return fmt.Errorf("%w (at %s)", ErrUpdatedRecently, lastUpdated.Format(time.RFC3339))
 	}
 
+	return s.ForceUpdateAvoidList()
+}
+
+// ForceUpdateAvoidList updates the avoid list from the remote API even if it was updated recently
+func (s *Service) ForceUpdateAvoidList() error {
+	return s.refresh(context.Background())
+}
+
+// refresh fetches the avoid list from Dune and swaps it in. When the fetch
+// fails the current entries are kept, and the error is recorded for the stats.
+// Manual decisions live in the overrides, which a refresh leaves alone.
+func (s *Service) refresh(ctx context.Context) error {
+	entries, err := s.fetchFromDune(ctx)
+
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	if err != nil {
+		s.lastRefreshError = err.Error()
+		s.lastErrorAt = time.Now()
+		s.mutex.Unlock()
+		metrics.AvoidListRefreshes.WithLabelValues("error").Inc()
+		return err
+	}
 
-	// Update the entries map
-	s.entries = make(map[string]domain.AvoidListEntry, len(response.Result.Rows))
-	for _, entry := range response.Result.Rows {
-		for idx, _ := range entry.ZippedPrefix {
-			prefix := entry.ZippedPrefix[idx]
-			_type := entry.ZippedType[idx]
-			s.entries[prefix] = domain.AvoidListEntry{
-				Prefix: prefix,
-				Type:   _type,
+	// Dune only supplies prefixes, so keep the full addresses added by hand or built from RPC
+	for key, entry := range s.entries {
+		if entry.Address != "" {
+			if _, found := entries[key]; !found {
+				entries[key] = entry
 			}
 		}
 	}
+
+	count := len(entries)
+	s.setEntries(entries)
 	s.lastUpdated = time.Now()
+	s.lastRefreshAt = s.lastUpdated
+	s.mutex.Unlock()
+	metrics.AvoidListRefreshes.WithLabelValues("success").Inc()
 
 	// Save to file
 	if err := s.saveToFile(); err != nil {
@@ -179,30 +370,160 @@ func (s *Service) UpdateAvoidList() error {
 		// Continue anyway as we've updated the in-memory list
 	}
 
-	log.Infof("Updated avoid list with %d entries", len(s.entries))
+	log.Infof("Updated avoid list with %d entries", count)
 	return nil
 }
 
-// ShouldAvoid checks if an address should be avoided
-func (s *Service) ShouldAvoid(address string) (bool, string) {
-	// Need at least 8 characters to check
-	if len(address) < 8 {
-		return false, ""
+// fetchFromDune fetches every row of the avoid list query, from its latest
+// execution or from a fresh one
+func (s *Service) fetchFromDune(ctx context.Context) (map[string]domain.AvoidListEntry, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("API key not provided for avoid list")
 	}
 
-	prefix := address[:8]
+	var rows []json.RawMessage
+	var err error
+	if s.executeQuery {
+		rows, err = s.dune.ExecuteAndFetch(ctx, s.queryID)
+	} else {
+		rows, err = s.dune.LatestResults(ctx, s.queryID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch avoid list from Dune query %d: %w", s.queryID, err)
+	}
+
+	entries := make(map[string]domain.AvoidListEntry)
+	for _, row := range rows {
+		var zipped domain.AvoidListZipped
+		if err := json.Unmarshal(row, &zipped); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Dune row: %w", err)
+		}
+		for idx := range zipped.ZippedPrefix {
+			if idx >= len(zipped.ZippedType) {
+				break
+			}
+			entry, err := normalizeEntry(domain.AvoidListEntry{
+				Prefix: zipped.ZippedPrefix[idx],
+				Type:   zipped.ZippedType[idx],
+			})
+			if err == nil {
+				entries[entry.Key()] = entry
+			}
+		}
+	}
+
+	// An empty result is far more likely a broken query than a clean chain
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("API returned an empty avoid list")
+	}
+	return entries, nil
+}
+
+// MergeEntries adds entries to the list, replacing any stored under the same
+// address or prefix, and saves it. It returns the number of entries added or changed.
+func (s *Service) MergeEntries(entries []domain.AvoidListEntry) (int, error) {
+	s.mutex.Lock()
+	merged := make(map[string]domain.AvoidListEntry, len(s.entries)+len(entries))
+	for key, entry := range s.entries {
+		merged[key] = entry
+	}
+	changed := 0
+	for _, entry := range entries {
+		entry, err := normalizeEntry(entry)
+		if err != nil {
+			log.Warnf("Skipping avoid list entry %s: %v", entry.Key(), err)
+			continue
+		}
+		if existing, found := merged[entry.Key()]; !found || existing != entry {
+			changed++
+		}
+		merged[entry.Key()] = entry
+	}
+
+	s.setEntries(merged)
+	s.lastUpdated = time.Now()
+	s.mutex.Unlock()
+
+	return changed, s.saveToFile()
+}
+
+// ShouldAvoid checks if an address should be avoided and explains why. Manual
+// overrides are consulted first, so an allow override beats any avoid list entry.
+func (s *Service) ShouldAvoid(address string) (bool, domain.AvoidReason) {
+	var reason domain.AvoidReason
+	if override, ok := s.GetOverride(address); ok {
+		if override.Action == domain.OverrideAllow {
+			return false, reason
+		}
+		reason = domain.AvoidReason{
+			Address:  address,
+			Kind:     domain.KindOfType(override.Type),
+			Category: override.CategoryOrDefault(),
+			Source:   domain.AvoidSourceOverride,
+			Detail:   fmt.Sprintf("%s: %s", override.CategoryOrDefault().Description(), override.Reason),
+		}
+	} else if entry, ok := s.GetEntry(address); ok {
+		reason = domain.AvoidReason{
+			Address:  address,
+			Kind:     domain.KindOfType(entry.Type),
+			Category: entry.CategoryOrDefault(),
+			Source:   domain.AvoidSourceList,
+			Detail:   entry.CategoryOrDefault().Description(),
+		}
+	} else {
+		return false, reason
+	}
+
+	kind := reason.Kind
+	if kind == "" {
+		kind = "unknown"
+	}
+	metrics.AvoidListSkips.WithLabelValues(kind, string(reason.Category)).Inc()
+	return true, reason
+}
+
+// GetEntry returns the entry matching an address: an exact address entry if
+// there is one, otherwise the entry with the longest matching prefix
+func (s *Service) GetEntry(address string) (domain.AvoidListEntry, bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	if entry, ok := s.entries[prefix]; ok {
-		if entry.Type == "t" {
-			return true, "token with too many holders (>100k)"
-		} else if entry.Type == "w" {
-			return true, "wallet with too many tokens (>500)"
-		}
+	return s.index.lookup(address)
+}
+
+// AddEntry adds or replaces an entry and saves the list. Keys of address length
+// are stored as full addresses and match only that address; shorter keys are
+// prefixes of at least 8 characters. Only the changed key is re-indexed.
+func (s *Service) AddEntry(entry domain.AvoidListEntry) error {
+	entry, err := normalizeEntry(entry)
+	if err != nil {
+		return err
 	}
 
-	return false, ""
+	s.mutex.Lock()
+	s.entries[entry.Key()] = entry
+	s.index.set(entry.Key(), entry)
+	s.mutex.Unlock()
+
+	return s.saveToFile()
+}
+
+// RemoveEntry removes the entry stored under an address or prefix and saves the list
+func (s *Service) RemoveEntry(key string) (bool, error) {
+	if len(key) < MinPrefixLength {
+		return false, fmt.Errorf("prefix must be at least %d characters", MinPrefixLength)
+	}
+
+	s.mutex.Lock()
+	if _, ok := s.entries[key]; !ok {
+		s.mutex.Unlock()
+		return false, nil
+	}
+	delete(s.entries, key)
+	s.index.remove(key)
+	s.mutex.Unlock()
+
+	return true, s.saveToFile()
 }
 
 // GetAvoidListStats returns statistics about the avoid list
@@ -210,21 +531,33 @@ func (s *Service) GetAvoidListStats() map[string]interface{} {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	// Count by type
+	// Count by type and category; deny overrides count towards their category too
 	tokenCount := 0
 	walletCount := 0
+	categories := make(map[domain.AvoidCategory]int)
 	for _, entry := range s.entries {
 		if entry.Type == "t" {
 			tokenCount++
 		} else if entry.Type == "w" {
 			walletCount++
 		}
+		categories[entry.CategoryOrDefault()]++
+	}
+	for _, override := range s.overrides {
+		if override.Action == domain.OverrideDeny {
+			categories[override.CategoryOrDefault()]++
+		}
 	}
 
 	return map[string]interface{}{
-		"totalEntries": len(s.entries),
-		"tokenCount":   tokenCount,
-		"walletCount":  walletCount,
-		"lastUpdated":  s.lastUpdated.Format(time.RFC3339),
+		"totalEntries":   len(s.entries),
+		"addressEntries": len(s.index.exact),
+		"prefixEntries":  len(s.index.prefixes),
+		"tokenCount":     tokenCount,
+		"walletCount":    walletCount,
+		"lastUpdated":    s.lastUpdated.Format(time.RFC3339),
+		"refresh":        s.refreshStats(),
+		"overrides":      s.overrideStats(),
+		"categories":     categories,
 	}
 }
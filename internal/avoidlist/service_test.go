@@ -0,0 +1,50 @@
+package avoidlist
+
+import (
+	"path/filepath"
+	"testing"
+
+	"wallet-guesser/internal/domain"
+)
+
+func TestOwnWritesAreNotReportedAsChanges(t *testing.T) {
+	dir := t.TempDir()
+	listPath := filepath.Join(dir, "avoidlist.bin")
+	service := NewService("", listPath, WithOverridesPath(filepath.Join(dir, "overrides.json")))
+
+	if err := service.AddEntry(domain.AvoidListEntry{Prefix: "7xKXtg2C", Type: "t"}); err != nil {
+		t.Fatalf("AddEntry: %v", err)
+	}
+	if service.FileChanged() {
+		t.Fatal("the service's own save was reported as a change")
+	}
+	override := domain.AvoidListOverride{Prefix: "7xKXtg2C", Action: domain.OverrideAllow, Reason: "test", Author: "test"}
+	if err := service.SetOverride(override); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	if service.OverridesChanged() {
+		t.Fatal("the service's own overrides save was reported as a change")
+	}
+
+	// Another writer, such as cmd/updateavoidlist, changes the file
+	other := NewService("", listPath)
+	if err := other.LoadFromFile(); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if err := other.AddEntry(domain.AvoidListEntry{Prefix: "9WzDXwBb", Type: "w", Category: "exchange"}); err != nil {
+		t.Fatalf("AddEntry: %v", err)
+	}
+	if !service.FileChanged() {
+		t.Fatal("another writer's save was not reported as a change")
+	}
+
+	if err := service.LoadFromFile(); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if service.FileChanged() {
+		t.Fatal("file still reported as changed after loading it")
+	}
+	if _, found := service.GetEntry("9WzDXwBbxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"); !found {
+		t.Fatal("reloaded list is missing the other writer's entry")
+	}
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Indexes = func() [256]int {
+	var indexes [256]int
+	for i := range indexes {
+		indexes[i] = -1
+	}
+	for i := 0; i < len(base58Alphabet); i++ {
+		indexes[base58Alphabet[i]] = i
+	}
+	return indexes
+}()
+
+// DecodeBase58 decodes a base58 string (Bitcoin alphabet) as used for Solana addresses and signatures
+func DecodeBase58(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, fmt.Errorf("empty base58 string")
+	}
+
+	result := new(big.Int)
+	radix := big.NewInt(58)
+	leadingZeros := 0
+	countingZeros := true
+
+	for i := 0; i < len(encoded); i++ {
+		digit := base58Indexes[encoded[i]]
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q at position %d", encoded[i], i)
+		}
+		if countingZeros && digit == 0 {
+			leadingZeros++
+			continue
+		}
+		countingZeros = false
+		result.Mul(result, radix)
+		result.Add(result, big.NewInt(int64(digit)))
+	}
+
+	decoded := result.Bytes()
+	return append(make([]byte, leadingZeros), decoded...), nil
+}
+
+// EncodeBase58 encodes bytes as a base58 string (Bitcoin alphabet)
+func EncodeBase58(data []byte) string {
+	leadingZeros := 0
+	for leadingZeros < len(data) && data[leadingZeros] == 0 {
+		leadingZeros++
+	}
+
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < leadingZeros; i++ {
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	// Reverse into big-endian order
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
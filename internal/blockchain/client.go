@@ -2,6 +2,7 @@ package blockchain
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,29 +11,79 @@ import (
 	"time"
 
 	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/metrics"
+	"wallet-guesser/internal/tracing"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Client handles interactions with the Solana blockchain
 type Client struct {
-	rpcEndpoint string
-	httpClient  *http.Client
-	avoidList   domain.AvoidListService
-	walletCache map[string][]string // token -> wallets
-	cacheMutex  sync.RWMutex
+	rpcEndpoint   string
+	endpointMutex sync.RWMutex
+	httpClient    *http.Client
+	avoidList     domain.AvoidListService
+	walletCache   map[string][]string // token -> wallets
+	cacheMutex    sync.RWMutex
+
+	includeProgramOwners bool // keep PDAs and known programs as holders
+}
+
+// ClientOption is a functional option for configuring the blockchain client
+type ClientOption func(*Client)
+
+// WithTimeout sets the timeout of each RPC request
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithProgramOwners keeps token accounts owned by PDAs and known programs,
+// such as AMM vaults and pool authorities, which are dropped by default
+func WithProgramOwners(include bool) ClientOption {
+	return func(c *Client) {
+		c.includeProgramOwners = include
+	}
 }
 
 // NewClient creates a new blockchain client
-func NewClient(rpcEndpoint string, avoidList domain.AvoidListService) *Client {
-	return &Client{
+func NewClient(rpcEndpoint string, avoidList domain.AvoidListService, options ...ClientOption) *Client {
+	c := &Client{
 		rpcEndpoint: rpcEndpoint,
 		httpClient:  &http.Client{Timeout: 30 * time.Second},
 		avoidList:   avoidList,
 		walletCache: make(map[string][]string),
 	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// RPCEndpoint returns the RPC endpoint requests are sent to
+func (c *Client) RPCEndpoint() string {
+	c.endpointMutex.RLock()
+	defer c.endpointMutex.RUnlock()
+
+	return c.rpcEndpoint
+}
+
+// SetRPCEndpoint switches the RPC endpoint. Requests already in flight finish
+// against the old endpoint; later ones use the new one.
+func (c *Client) SetRPCEndpoint(rpcEndpoint string) {
+	c.endpointMutex.Lock()
+	defer c.endpointMutex.Unlock()
+
+	c.rpcEndpoint = rpcEndpoint
 }
 
 // GetProgramAccounts fetches all accounts owned by a program
-func (c *Client) GetProgramAccounts(programID string, filters []map[string]interface{}, progressCallback domain.ProgressCallback) ([]map[string]interface{}, error) {
+func (c *Client) GetProgramAccounts(ctx context.Context, programID string, filters []map[string]interface{}, progressCallback domain.ProgressCallback) ([]map[string]interface{}, error) {
 
 	// Prepare the RPC request
 	req := RpcRequest{
@@ -49,7 +100,7 @@ func (c *Client) GetProgramAccounts(programID string, filters []map[string]inter
 	}
 
 	// Send the request
-	rpcResp, err := c.sendRpcRequest(req)
+	rpcResp, err := c.sendRpcRequest(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -64,13 +115,23 @@ func (c *Client) GetProgramAccounts(programID string, filters []map[string]inter
 }
 
 // GetWalletsForToken returns all wallet addresses that have interacted with a specific token
-func (c *Client) GetWalletsForToken(mintAddress string, progressCallback domain.ProgressCallback) ([]string, error) {
+func (c *Client) GetWalletsForToken(ctx context.Context, mintAddress string, progressCallback domain.ProgressCallback) ([]string, error) {
+	ctx = logging.WithField(ctx, logging.FieldMint, mintAddress)
+	ctx, span := tracing.Start(ctx, "blockchain.GetWalletsForToken", attribute.String("solana.mint", mintAddress))
+	start := time.Now()
+	outcome := metrics.OutcomeSuccess
+	var err error
+	defer func() {
+		span.SetAttributes(attribute.String("outcome", outcome))
+		tracing.End(span, err)
+		metrics.ObserveDuration(metrics.GetWalletsForTokenDuration, outcome, start)
+	}()
+
 	// Check if the token should be avoided
 	if c.avoidList != nil {
 		if shouldAvoid, reason := c.avoidList.ShouldAvoid(mintAddress); shouldAvoid {
-			if progressCallback != nil {
-				progressCallback(fmt.Sprintf("Skipping token %s: %s", mintAddress, reason))
-			}
+			outcome = metrics.OutcomeAvoided
+			progressCallback.ReportExclusion(fmt.Sprintf("Skipping token %s: %s", mintAddress, reason), reason)
 			return nil, nil
 		}
 	}
@@ -79,11 +140,11 @@ func (c *Client) GetWalletsForToken(mintAddress string, progressCallback domain.
 	c.cacheMutex.RLock()
 	cachedWallets, found := c.walletCache[mintAddress]
 	c.cacheMutex.RUnlock()
+	metrics.CacheLookup("token_holders", found)
 
 	if found {
-		if progressCallback != nil {
-			progressCallback(fmt.Sprintf("Using cached data for token %s (%d wallets)", mintAddress, len(cachedWallets)))
-		}
+		outcome = metrics.OutcomeCached
+		progressCallback.Report(fmt.Sprintf("Using cached data for token %s (%d wallets)", mintAddress, len(cachedWallets)))
 		return cachedWallets, nil
 	}
 
@@ -93,13 +154,18 @@ func (c *Client) GetWalletsForToken(mintAddress string, progressCallback domain.
 	}
 
 	// Get all token accounts for this mint
-	accounts, err := c.GetProgramAccounts(TokenProgramID, filters, progressCallback)
+	accounts, err := c.GetProgramAccounts(ctx, TokenProgramID, filters, progressCallback)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get program accounts: %w", err)
+		outcome = metrics.OutcomeError
+		err = fmt.Errorf("failed to get program accounts: %w", err)
+		return nil, err
 	}
 
 	// Extract wallet addresses (owners) from the accounts
 	wallets := make(map[string]struct{}) // Use map to deduplicate
+	avoided := make(map[string]bool)     // Report each avoided owner once
+	programOwners := make(map[string]bool)
+	logger := logging.FromContext(ctx)
 
 	for _, account := range accounts {
 		// Extract owner address from account data
@@ -108,9 +174,29 @@ func (c *Client) GetWalletsForToken(mintAddress string, progressCallback domain.
 				if parsed, ok := data["parsed"].(map[string]interface{}); ok {
 					if info, ok := parsed["info"].(map[string]interface{}); ok {
 						if owner, ok := info["owner"].(string); ok {
+							// Drop vaults and authorities owned by programs rather than users
+							if !c.includeProgramOwners {
+								if programOwners[owner] {
+									continue
+								}
+								if kind, label := ClassifyOwner(owner); kind != OwnerWallet {
+									programOwners[owner] = true
+									if label == "" {
+										label = "unknown program"
+									}
+									logger.Debugf("Dropping %s holder %s of token %s (%s)", kind, owner, mintAddress, label)
+									continue
+								}
+							}
+
 							// Check if the wallet should be avoided
 							if c.avoidList != nil {
-								if shouldAvoid, _ := c.avoidList.ShouldAvoid(owner); shouldAvoid {
+								if avoided[owner] {
+									continue
+								}
+								if shouldAvoid, reason := c.avoidList.ShouldAvoid(owner); shouldAvoid {
+									avoided[owner] = true
+									progressCallback.ReportExclusion(fmt.Sprintf("Skipping holder %s of token %s: %s", owner, mintAddress, reason), reason)
 									continue
 								}
 							}
@@ -133,9 +219,10 @@ func (c *Client) GetWalletsForToken(mintAddress string, progressCallback domain.
 	c.walletCache[mintAddress] = result
 	c.cacheMutex.Unlock()
 
-	if progressCallback != nil {
-		progressCallback(fmt.Sprintf("Found %d wallets that interacted with token %s", len(result), mintAddress))
+	if len(programOwners) > 0 {
+		progressCallback.Report(fmt.Sprintf("Ignored %d program-owned accounts of token %s", len(programOwners), mintAddress))
 	}
+	progressCallback.Report(fmt.Sprintf("Found %d wallets that interacted with token %s", len(result), mintAddress))
 
 	return result, nil
 }
@@ -151,18 +238,118 @@ func (c *Client) GetTokenInfo(mintAddress string) (map[string]interface{}, error
 	}, nil
 }
 
-// sendRpcRequest sends a JSON-RPC request to the Solana node
-func (c *Client) sendRpcRequest(request RpcRequest) (*RpcResponse, error) {
+// GetHealth checks the RPC node's health. It returns an error when the node is unhealthy or unreachable.
+func (c *Client) GetHealth(ctx context.Context) error {
+	rpcResp, err := c.sendRpcRequest(ctx, RpcRequest{
+		Jsonrpc: "2.0",
+		ID:      1,
+		Method:  "getHealth",
+		Params:  []interface{}{},
+	})
+	if err != nil {
+		return err
+	}
+
+	var status string
+	if err := json.Unmarshal(rpcResp.Result, &status); err != nil {
+		return fmt.Errorf("failed to unmarshal health: %w", err)
+	}
+	if status != "ok" {
+		return fmt.Errorf("node reported health %q", status)
+	}
+	return nil
+}
+
+// GetSlot returns the slot the RPC node has processed up to
+func (c *Client) GetSlot(ctx context.Context) (uint64, error) {
+	rpcResp, err := c.sendRpcRequest(ctx, RpcRequest{
+		Jsonrpc: "2.0",
+		ID:      1,
+		Method:  "getSlot",
+		Params:  []interface{}{},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var slot uint64
+	if err := json.Unmarshal(rpcResp.Result, &slot); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal slot: %w", err)
+	}
+	return slot, nil
+}
+
+// ClearCache clears the token holder cache
+func (c *Client) ClearCache() {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+	c.walletCache = make(map[string][]string)
+}
+
+// ClearTokenCache removes one token from the holder cache
+func (c *Client) ClearTokenCache(mintAddress string) bool {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	_, found := c.walletCache[mintAddress]
+	delete(c.walletCache, mintAddress)
+	return found
+}
+
+// CacheStats returns statistics about the token holder cache
+func (c *Client) CacheStats() map[string]interface{} {
+	c.cacheMutex.RLock()
+	defer c.cacheMutex.RUnlock()
+
+	walletCount := 0
+	for _, wallets := range c.walletCache {
+		walletCount += len(wallets)
+	}
+
+	return map[string]interface{}{
+		"tokensSize":  len(c.walletCache),
+		"walletsSize": walletCount,
+	}
+}
+
+// sendRpcRequest sends a JSON-RPC request to the Solana node and counts it by method and status
+func (c *Client) sendRpcRequest(ctx context.Context, request RpcRequest) (*RpcResponse, error) {
+	attrs := []attribute.KeyValue{attribute.String("rpc.method", request.Method)}
+	if mintAddress := logging.Value(ctx, logging.FieldMint); mintAddress != "" {
+		attrs = append(attrs, attribute.String("solana.mint", mintAddress))
+	}
+	ctx, span := tracing.Start(ctx, "solana.rpc "+request.Method, attrs...)
+
+	start := time.Now()
+	rpcResp, status, err := c.doRpcRequest(ctx, request)
+	span.SetAttributes(attribute.String("rpc.status", status))
+	tracing.End(span, err)
+	metrics.RPCRequests.WithLabelValues(request.Method, status).Inc()
+
+	entry := logging.FromContext(ctx).WithFields(log.Fields{
+		logging.FieldRPCMethod: request.Method,
+		logging.FieldDuration:  logging.Duration(start),
+	})
+	if err != nil {
+		entry.Warnf("RPC request failed: %v", err)
+	} else {
+		entry.Debugf("RPC request completed")
+	}
+	return rpcResp, err
+}
+
+// doRpcRequest performs the HTTP round trip for sendRpcRequest, returning a status label for metrics
+func (c *Client) doRpcRequest(ctx context.Context, request RpcRequest) (*RpcResponse, string, error) {
 	// Marshal the request
 	reqBody, err := json.Marshal(request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, "request_error", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create an HTTP request
-	httpReq, err := http.NewRequest("POST", c.rpcEndpoint, bytes.NewReader(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.RPCEndpoint(), bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, "request_error", fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
 	// Set headers
@@ -171,26 +358,26 @@ func (c *Client) sendRpcRequest(request RpcRequest) (*RpcResponse, error) {
 	// Send the request
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+		return nil, "transport_error", fmt.Errorf("failed to send HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, "transport_error", fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	// Parse the response
 	var rpcResp RpcResponse
 	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, fmt.Sprintf("http_%d", resp.StatusCode), fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	// Check if there was an RPC error
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("RPC error: %s (code %d)", rpcResp.Error.Message, rpcResp.Error.Code)
+		return nil, "rpc_error", fmt.Errorf("RPC error: %s (code %d)", rpcResp.Error.Message, rpcResp.Error.Code)
 	}
 
-	return &rpcResp, nil
+	return &rpcResp, "ok", nil
 }
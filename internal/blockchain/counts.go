@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"wallet-guesser/internal/logging"
+)
+
+const (
+	// tokenAccountSize is the size of an SPL token account
+	tokenAccountSize = 165
+	// tokenAmountOffset is where the u64 balance sits in an SPL token account
+	tokenAmountOffset = 64
+)
+
+// slicedAccount is an account returned with only the balance bytes of its data
+type slicedAccount struct {
+	Pubkey  string `json:"pubkey"`
+	Account struct {
+		Data []string `json:"data"` // [base64 data, "base64"]
+	} `json:"account"`
+}
+
+// amountSlice asks the RPC node for just the balance of each token account,
+// which keeps responses small even for tokens with hundreds of thousands of holders
+var amountSlice = map[string]interface{}{
+	"encoding":  "base64",
+	"dataSlice": map[string]interface{}{"offset": tokenAmountOffset, "length": 8},
+}
+
+// CountTokenHolders returns the number of token accounts holding a non-zero
+// balance of a token. Unlike GetWalletsForToken it ignores the avoid list and
+// the cache, and does not fetch the owners.
+func (c *Client) CountTokenHolders(ctx context.Context, mintAddress string) (int, error) {
+	ctx = logging.WithField(ctx, logging.FieldMint, mintAddress)
+
+	config := map[string]interface{}{
+		"filters": []map[string]interface{}{
+			{"dataSize": tokenAccountSize},
+			TokenBalanceFilter(mintAddress),
+		},
+	}
+	for key, value := range amountSlice {
+		config[key] = value
+	}
+
+	rpcResp, err := c.sendRpcRequest(ctx, RpcRequest{
+		Jsonrpc: "2.0",
+		ID:      1,
+		Method:  "getProgramAccounts",
+		Params:  []interface{}{TokenProgramID, config},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var accounts []slicedAccount
+	if err := json.Unmarshal(rpcResp.Result, &accounts); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal program accounts: %w", err)
+	}
+	return countNonZero(accounts)
+}
+
+// CountWalletTokens returns the number of token accounts with a non-zero balance owned by a wallet
+func (c *Client) CountWalletTokens(ctx context.Context, walletAddress string) (int, error) {
+	rpcResp, err := c.sendRpcRequest(ctx, RpcRequest{
+		Jsonrpc: "2.0",
+		ID:      1,
+		Method:  "getTokenAccountsByOwner",
+		Params: []interface{}{
+			walletAddress,
+			map[string]interface{}{"programId": TokenProgramID},
+			amountSlice,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Value []slicedAccount `json:"value"`
+	}
+	if err := json.Unmarshal(rpcResp.Result, &result); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal token accounts: %w", err)
+	}
+	return countNonZero(result.Value)
+}
+
+// countNonZero counts the accounts whose sliced balance is above zero
+func countNonZero(accounts []slicedAccount) (int, error) {
+	count := 0
+	for _, account := range accounts {
+		if len(account.Account.Data) == 0 {
+			continue
+		}
+		amount, err := base64.StdEncoding.DecodeString(account.Account.Data[0])
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode balance of %s: %w", account.Pubkey, err)
+		}
+		if len(amount) == 8 && binary.LittleEndian.Uint64(amount) > 0 {
+			count++
+		}
+	}
+	return count, nil
+}
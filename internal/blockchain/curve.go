@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"math/big"
+)
+
+// Ed25519 field and curve constants. A public key is the compressed y
+// coordinate of a point on -x^2 + y^2 = 1 + d*x^2*y^2 over GF(2^255 - 19).
+var (
+	curveP = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	curveD = func() *big.Int {
+		d := new(big.Int).ModInverse(big.NewInt(121666), curveP)
+		d.Mul(d, big.NewInt(-121665))
+		return d.Mod(d, curveP)
+	}()
+)
+
+// IsOnCurve reports whether an address is a point on the Ed25519 curve, meaning
+// a keypair can sign for it. Program derived addresses are deliberately off the
+// curve, so an owner that is not on it is a program rather than a user wallet.
+// Addresses that do not decode to 32 bytes are reported as off the curve.
+func IsOnCurve(address string) bool {
+	key, err := DecodeBase58(address)
+	if err != nil || len(key) != 32 {
+		return false
+	}
+	return isOnCurve(key)
+}
+
+// isOnCurve checks that the point compressed in a 32 byte key can be
+// decompressed, the same check Solana uses when deriving program addresses:
+// x^2 = (y^2 - 1) / (d*y^2 + 1) must have a square root.
+func isOnCurve(key []byte) bool {
+	// The key is little endian, with the sign of x in the top bit
+	le := make([]byte, 32)
+	for i := range key {
+		le[31-i] = key[i]
+	}
+	le[0] &= 0x7f
+	y := new(big.Int).SetBytes(le)
+	y.Mod(y, curveP)
+
+	y2 := new(big.Int).Mul(y, y)
+	y2.Mod(y2, curveP)
+
+	u := new(big.Int).Sub(y2, big.NewInt(1))
+	u.Mod(u, curveP)
+	v := new(big.Int).Mul(curveD, y2)
+	v.Add(v, big.NewInt(1))
+	v.Mod(v, curveP)
+
+	// v is never zero because d is not a square
+	x2 := new(big.Int).ModInverse(v, curveP)
+	x2.Mul(x2, u)
+	x2.Mod(x2, curveP)
+
+	// p is prime, so the Jacobi symbol tells whether x^2 has a root
+	return big.Jacobi(x2, curveP) >= 0
+}
@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// holderCacheFile is the persisted form of the token holder cache
+type holderCacheFile struct {
+	SavedAt time.Time           `json:"savedAt"`
+	Holders map[string][]string `json:"holders"` // token -> wallets
+}
+
+// ReadHolderCache reads a file written by SaveHolderCache, returning the
+// wallets seen for each token. A missing file yields an empty map.
+func ReadHolderCache(filePath string) (map[string][]string, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read holder cache: %w", err)
+	}
+
+	var fileData holderCacheFile
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal holder cache: %w", err)
+	}
+	if fileData.Holders == nil {
+		fileData.Holders = map[string][]string{}
+	}
+	return fileData.Holders, nil
+}
+
+// LoadHolderCache fills the token holder cache from a file written by
+// SaveHolderCache. It returns the number of tokens loaded.
+func (c *Client) LoadHolderCache(filePath string) (int, error) {
+	holders, err := ReadHolderCache(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	for mintAddress, wallets := range holders {
+		c.walletCache[mintAddress] = wallets
+	}
+	return len(holders), nil
+}
+
+// SaveHolderCache atomically writes the token holder cache to a file, so the
+// holders survive a restart and the tokens can feed the avoid list builder.
+// It returns the number of tokens saved.
+func (c *Client) SaveHolderCache(filePath string) (int, error) {
+	c.cacheMutex.RLock()
+	data, err := json.Marshal(holderCacheFile{
+		SavedAt: time.Now(),
+		Holders: c.walletCache,
+	})
+	count := len(c.walletCache)
+	c.cacheMutex.RUnlock()
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal holder cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory for holder cache: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated cache
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write holder cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return 0, fmt.Errorf("failed to replace holder cache: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,54 @@
+package blockchain
+
+// OwnerKind classifies the owner of a token account
+type OwnerKind string
+
+// Owner kinds. Only wallets are kept as holders unless program owners are included.
+const (
+	OwnerWallet  OwnerKind = "wallet"  // an on-curve address a user holds the key for
+	OwnerPDA     OwnerKind = "pda"     // an off-curve program derived address
+	OwnerProgram OwnerKind = "program" // a well-known program or system address
+)
+
+// knownPrograms labels programs, program authorities and system addresses that
+// show up as token account owners. Some of them are on the curve, so they are
+// matched by address rather than by the curve check.
+var knownPrograms = map[string]string{
+	"11111111111111111111111111111111":             "System Program",
+	"1nc1nerator11111111111111111111111111111111":  "Incinerator",
+	"TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA":  "Token Program",
+	"TokenzQdBNbLqP5VEhdkAS6EPFLC1PHnBqCXEpPxuEb":  "Token-2022 Program",
+	"ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL": "Associated Token Program",
+	"metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bt518x1s":  "Metaplex Token Metadata",
+	"675kPX9MHTjS2zt1qfr1NYHuzeLXfQM9H24wFSUt1Mp8": "Raydium AMM v4",
+	"5Q544fKrFoe6tsEbD7S8EmxGTJYAKtTVhAW5Q5pge4j1": "Raydium AMM v4 Authority",
+	"CAMMCzo5YL8w4VFF8KVHrK22GGUsp5VTaW7grrKgrWqK": "Raydium CLMM",
+	"CPMMoo8L3F4NbTegBCKVNunggL7H1ZpdTHKxQB5qKP1C": "Raydium CPMM",
+	"GpMZbSM2GgvTKHJirzeGfMFoaZ8UR2X7F4v8vHTvxFbL": "Raydium CPMM Authority",
+	"whirLbMiicVdio4qvUfM5KAg6Ct8VwpYzGff3uctyCc":  "Orca Whirlpool",
+	"9W959DqEETiGZocYWCQPaJ6sBmUzgfxXfqGeTEdp3aQP": "Orca Token Swap v2",
+	"LBUZKhRxPF3XUpBCjp4YzTKgLccjZhTSDM9YuVaPwxo":  "Meteora DLMM",
+	"Eo7WjKq67rjJQSZxS6z3YkapzY3eMj6Xy8X5EQVn5UaB": "Meteora Pools",
+	"6EF8rrecthR5Dkzon8Nwu78hRvfCKubJ14M5uBEwF6P":  "Pump.fun",
+	"39azUYFWPz3VHgKCf3VChUwbpURdCHRxjWVowf5jUJjg": "Pump.fun Migration Authority",
+	"JUP6LkbZbjS1jKKwapdHNy74zcZ3tLUZoi5QNyVTaV4":  "Jupiter Aggregator v6",
+	"srmqPvymJeFKQ4zGQed1GFppgkRHL9kaELCbyksJtPX":  "OpenBook",
+}
+
+// KnownProgram returns the label of a well-known program or system address
+func KnownProgram(address string) (string, bool) {
+	label, ok := knownPrograms[address]
+	return label, ok
+}
+
+// ClassifyOwner tells user wallets apart from programs. Known programs are
+// returned with their label, other off-curve addresses as unlabelled PDAs.
+func ClassifyOwner(address string) (OwnerKind, string) {
+	if label, ok := KnownProgram(address); ok {
+		return OwnerProgram, label
+	}
+	if !IsOnCurve(address) {
+		return OwnerPDA, ""
+	}
+	return OwnerWallet, ""
+}
@@ -0,0 +1,58 @@
+// Package buildinfo exposes the version and commit the binary was built from.
+// Both are injected at build time:
+//
+//	go build -ldflags "-X wallet-guesser/internal/buildinfo.Version=1.2.0 -X wallet-guesser/internal/buildinfo.Commit=$(git rev-parse HEAD)" ./cmd/server
+package buildinfo
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+var (
+	// Version is the release version, set with -ldflags
+	Version = "dev"
+	// Commit is the git commit, set with -ldflags or taken from the Go build info
+	Commit = ""
+	// BuildTime is when the binary was built (RFC 3339), set with -ldflags
+	BuildTime = ""
+)
+
+// startTime is used to report uptime
+var startTime = time.Now()
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Uptime    string `json:"uptime"`
+}
+
+// Get returns information about the running build
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		Uptime:    time.Since(startTime).Round(time.Second).String(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = buildInfo.GoVersion
+		// Fall back to the VCS stamp the Go toolchain records when no commit was injected
+		if info.Commit == "" {
+			for _, setting := range buildInfo.Settings {
+				if setting.Key == "vcs.revision" {
+					info.Commit = setting.Value
+				}
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+
+	return info
+}
@@ -1,63 +1,266 @@
 package config
 
 import (
+	"flag"
+	"fmt"
 	"os"
-	"strconv"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Port              int
-	ApifyToken        string
-	SolanaRpcEndpoint string
-	DuneApiKey        string
-	AvoidListPath     string
-	Debug             bool
+	ConfigFile string // file the settings were read from, if any
+
+	Port            int
+	Debug           bool
+	LogFormat       string
+	AdminToken      string
+	TracingExporter string
+	ShutdownTimeout time.Duration
+	ReloadInterval  time.Duration
+
+	// Twitter via Apify
+	ApifyToken    string
+	ApifyActorURL string
+	ApifyTimeout  time.Duration
+	FollowLimit   int
+	ScanWebsites  bool
+
+	// Solana
+	SolanaRpcEndpoint    string
+	RpcTimeout           time.Duration
+	IncludeProgramOwners bool
+
+	// Avoid list
+	DuneApiKey               string
+	DuneQueryID              int
+	DuneExecute              bool
+	AvoidListPath            string
+	AvoidListOverridesPath   string
+	AvoidListRefreshInterval time.Duration
+
+	// Guessing
+	GuessWorkers       int
+	GuessQueueSize     int
+	GuessTimeout       time.Duration
+	MaxResults         int
+	ConfidentThreshold int
+	UncertainThreshold int
+	JobStorePath       string
+	ResultCachePath    string
+	HolderCachePath    string
+}
+
+// Default returns the configuration used when nothing overrides it
+func Default() *Config {
+	return &Config{
+		Port:            8080,
+		LogFormat:       "text",
+		TracingExporter: "none",
+		ShutdownTimeout: 30 * time.Second,
+		ReloadInterval:  10 * time.Second,
+
+		ApifyActorURL: "https://api.apify.com/v2/acts/kaitoeasyapi~premium-x-follower-scraper-following-data/run-sync-get-dataset-items",
+		ApifyTimeout:  30 * time.Second,
+		FollowLimit:   500,
+
+		RpcTimeout: 30 * time.Second,
+
+		DuneQueryID:              4966121,
+		AvoidListPath:            "data/avoidlist.bin",
+		AvoidListOverridesPath:   "data/avoidlist_overrides.json",
+		AvoidListRefreshInterval: 24 * time.Hour,
+
+		GuessWorkers:       4,
+		GuessQueueSize:     100,
+		GuessTimeout:       3 * time.Minute,
+		MaxResults:         5,
+		ConfidentThreshold: 70,
+		UncertainThreshold: 40,
+		JobStorePath:       "data/jobs.json",
+		ResultCachePath:    "data/results.json",
+		HolderCachePath:    "data/holders.json",
+	}
 }
 
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
+// settings binds every configurable field to the key it is known by in each layer
+func (c *Config) settings() []*setting {
+	return []*setting{
+		intSetting("port", "HTTP server port", &c.Port),
+		boolSetting("debug", "enable debug logging", &c.Debug),
+		stringSetting("log_format", "log format: text or json", &c.LogFormat),
+		stringSetting("admin_token", "bearer token for the admin API (disabled when empty)", &c.AdminToken),
+		stringSetting("tracing_exporter", "OpenTelemetry span exporter: none, stdout or otlp", &c.TracingExporter),
+		durationSetting("shutdown_timeout", "how long shutdown waits for running guesses", &c.ShutdownTimeout),
+		durationSetting("reload_interval", "how often the config and avoid list files are checked for changes (0 disables)", &c.ReloadInterval),
+
+		stringSetting("apify_token", "Apify API token for Twitter data", &c.ApifyToken),
+		stringSetting("apify_actor_url", "Apify actor endpoint that returns followed accounts", &c.ApifyActorURL),
+		durationSetting("apify_timeout", "timeout of a single Apify request", &c.ApifyTimeout),
+		intSetting("follow_limit", "number of followed accounts fetched per guess", &c.FollowLimit),
+		boolSetting("scan_websites", "scan followed accounts' websites for addresses", &c.ScanWebsites),
+
+		stringSetting("solana_rpc_endpoint", "Solana RPC endpoint", &c.SolanaRpcEndpoint),
+		durationSetting("rpc_timeout", "timeout of a single Solana RPC request", &c.RpcTimeout),
+		boolSetting("include_program_owners", "keep token holders that are PDAs or known programs", &c.IncludeProgramOwners),
+
+		stringSetting("dune_api_key", "Dune Analytics API key for the avoid list", &c.DuneApiKey),
+		intSetting("dune_query_id", "Dune query that returns the avoid list", &c.DuneQueryID),
+		boolSetting("dune_execute", "run the Dune query afresh on each refresh instead of reading its latest results", &c.DuneExecute),
+		stringSetting("avoid_list_path", "path to the avoid list file, binary when it ends in .bin and JSON otherwise", &c.AvoidListPath),
+		stringSetting("avoid_list_overrides_path", "path to the manual avoid list overrides file", &c.AvoidListOverridesPath),
+		durationSetting("avoid_list_refresh_interval", "how often the avoid list is refreshed from Dune (0 disables)", &c.AvoidListRefreshInterval),
+
+		intSetting("guess_workers", "number of guesses run concurrently", &c.GuessWorkers),
+		intSetting("guess_queue_size", "number of guesses allowed to wait for a worker", &c.GuessQueueSize),
+		durationSetting("guess_timeout", "maximum duration of a single guess", &c.GuessTimeout),
+		intSetting("max_results", "maximum number of addresses returned per guess", &c.MaxResults),
+		intSetting("confident_threshold", "minimum confidence for the Jinn to be confident", &c.ConfidentThreshold),
+		intSetting("uncertain_threshold", "minimum confidence for the Jinn to ask rather than give up", &c.UncertainThreshold),
+		stringSetting("job_store_path", "file where queued guesses are persisted", &c.JobStorePath),
+		stringSetting("result_cache_path", "file the guess result cache is saved to", &c.ResultCachePath),
+		stringSetting("holder_cache_path", "file the token holder cache is saved to", &c.HolderCachePath),
+	}
+}
+
+// Load builds the configuration from defaults, then a YAML or TOML config file,
+// then environment variables, then command line flags, each layer overriding the
+// one before. The config file is named by the -config flag or CONFIG_FILE. All
+// problems found are returned together rather than stopping at the first.
+func Load(args []string) (*Config, error) {
 	// Try to load .env file, but continue if it doesn't exist
-	if err := godotenv.Load(); err != nil {
-		log.Warnf("Error loading .env file: %v", err)
+	if err := loadDotEnv(); err != nil {
+		log.Debugf("Not loading .env file: %v", err)
 	}
 
-	port := 8080 // Default port
-	if portStr := os.Getenv("PORT"); portStr != "" {
-		if p, err := strconv.Atoi(portStr); err == nil {
-			port = p
+	cfg := Default()
+	settings := cfg.settings()
+
+	// Parse flags first to find the config file, but apply them last
+	flagSet := flag.NewFlagSet("wallet-guesser", flag.ContinueOnError)
+	configFile := flagSet.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.key] = flagSet.String(s.flagName(), s.String(), s.usage)
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+
+	// Config file
+	if *configFile != "" {
+		cfg.ConfigFile = *configFile
+		values, err := readFile(*configFile)
+		if err != nil {
+			errs = append(errs, err)
 		}
+		errs = append(errs, applyValues(settings, values, "config file")...)
 	}
 
-	solanaRpcEndpoint := os.Getenv("SOLANA_RPC_ENDPOINT")
-	if solanaRpcEndpoint == "" {
-		log.Fatalf("SOLANA_RPC_ENDPOINT environment variable not set")
+	// Environment variables
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.envName()); ok && value != "" {
+			if err := s.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("environment variable %s: %w", s.envName(), err))
+			}
+		}
 	}
 
-	// Debug mode
-	debug := false
-	if debugStr := os.Getenv("DEBUG"); debugStr == "true" {
-		debug = true
-		log.SetLevel(log.DebugLevel)
-	} else {
-		log.SetLevel(log.InfoLevel)
+	// Command line flags
+	bySetting := make(map[string]*setting, len(settings))
+	for _, s := range settings {
+		bySetting[s.flagName()] = s
 	}
+	flagSet.Visit(func(f *flag.Flag) {
+		s, ok := bySetting[f.Name]
+		if !ok {
+			return
+		}
+		if err := s.Set(*flagValues[s.key]); err != nil {
+			errs = append(errs, fmt.Errorf("flag -%s: %w", f.Name, err))
+		}
+	})
 
-	// Avoid list path
-	avoidListPath := os.Getenv("AVOID_LIST_PATH")
-	if avoidListPath == "" {
-		avoidListPath = "data/avoidlist.json"
+	errs = append(errs, cfg.Validate()...)
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
 	}
+	return cfg, nil
+}
 
-	return &Config{
-		Port:              port,
-		ApifyToken:        os.Getenv("APIFY_TOKEN"),
-		SolanaRpcEndpoint: solanaRpcEndpoint,
-		DuneApiKey:        os.Getenv("DUNE_API_KEY"),
-		AvoidListPath:     avoidListPath,
-		Debug:             debug,
-	}, nil
+// Diff returns the keys of the settings whose values differ between c and other
+func (c *Config) Diff(other *Config) []string {
+	otherSettings := other.settings()
+
+	var changed []string
+	for i, s := range c.settings() {
+		if s.String() != otherSettings[i].String() {
+			changed = append(changed, s.key)
+		}
+	}
+	return changed
+}
+
+// CopySetting sets the setting named key to its value in other
+func (c *Config) CopySetting(other *Config, key string) {
+	otherSettings := other.settings()
+	for i, s := range c.settings() {
+		if s.key == key {
+			_ = s.Set(otherSettings[i].String())
+			return
+		}
+	}
+}
+
+// applyValues sets the settings named in values, reporting unknown keys and bad values
+func applyValues(settings []*setting, values map[string]interface{}, source string) []error {
+	byKey := make(map[string]*setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		value := values[key]
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", source, key))
+			continue
+		}
+		if err := s.Set(fmt.Sprint(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", source, key, err))
+		}
+	}
+	return errs
+}
+
+// ValidationError lists every problem found while loading the configuration
+type ValidationError struct {
+	Errors []error
+}
+
+// Error implements error
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("%d configuration problem(s):\n%s", len(e.Errors), strings.Join(messages, "\n"))
+}
+
+// Unwrap returns the individual errors
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
 }
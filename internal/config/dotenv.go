@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// DotEnvFile is the file environment variables are read from alongside the real environment
+const DotEnvFile = ".env"
+
+var (
+	// processEnv names the variables the process was started with, which .env never overrides
+	processEnv = environNames()
+
+	dotEnvMutex sync.Mutex
+	// dotEnvApplied names the variables the last loadDotEnv set from .env
+	dotEnvApplied = make(map[string]bool)
+)
+
+// loadDotEnv sets the variables in .env that the process environment does not.
+// Unlike godotenv.Load it can be repeated, so a reload picks up values changed
+// in .env and unsets variables removed from it. A .env that cannot be parsed
+// leaves the variables from the last load in place.
+func loadDotEnv() error {
+	values, err := godotenv.Read(DotEnvFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	dotEnvMutex.Lock()
+	defer dotEnvMutex.Unlock()
+
+	for name := range dotEnvApplied {
+		if _, ok := values[name]; !ok {
+			os.Unsetenv(name)
+			delete(dotEnvApplied, name)
+		}
+	}
+	for name, value := range values {
+		if processEnv[name] {
+			continue
+		}
+		os.Setenv(name, value)
+		dotEnvApplied[name] = true
+	}
+	return err
+}
+
+// environNames returns the names of the variables currently in the environment
+func environNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		names[name] = true
+	}
+	return names
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is one configurable field. Its key is used as-is in config files,
+// upper-cased as an environment variable and with dashes as a command line flag.
+type setting struct {
+	key    string
+	usage  string
+	set    func(string) error
+	format func() string
+}
+
+// Set parses and stores a value
+func (s *setting) Set(value string) error {
+	return s.set(strings.TrimSpace(value))
+}
+
+// String returns the current value
+func (s *setting) String() string {
+	return s.format()
+}
+
+// envName is the environment variable for the setting, e.g. SOLANA_RPC_ENDPOINT
+func (s *setting) envName() string {
+	return strings.ToUpper(s.key)
+}
+
+// flagName is the command line flag for the setting, e.g. solana-rpc-endpoint
+func (s *setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// stringSetting binds a string field
+func stringSetting(key string, usage string, field *string) *setting {
+	return &setting{
+		key:   key,
+		usage: usage,
+		set: func(value string) error {
+			*field = value
+			return nil
+		},
+		format: func() string { return *field },
+	}
+}
+
+// intSetting binds an int field
+func intSetting(key string, usage string, field *int) *setting {
+	return &setting{
+		key:   key,
+		usage: usage,
+		set: func(value string) error {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%q is not a whole number", value)
+			}
+			*field = parsed
+			return nil
+		},
+		format: func() string { return strconv.Itoa(*field) },
+	}
+}
+
+// boolSetting binds a bool field
+func boolSetting(key string, usage string, field *bool) *setting {
+	return &setting{
+		key:   key,
+		usage: usage,
+		set: func(value string) error {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%q is not true or false", value)
+			}
+			*field = parsed
+			return nil
+		},
+		format: func() string { return strconv.FormatBool(*field) },
+	}
+}
+
+// durationSetting binds a duration field, written like "30s" or "3m"
+func durationSetting(key string, usage string, field *time.Duration) *setting {
+	return &setting{
+		key:   key,
+		usage: usage,
+		set: func(value string) error {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("%q is not a duration such as 30s or 3m", value)
+			}
+			*field = parsed
+			return nil
+		},
+		format: func() string { return field.String() },
+	}
+}
+
+// readFile reads the top-level keys of a YAML or TOML config file
+func readFile(filePath string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+	}
+	return values, nil
+}
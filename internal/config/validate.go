@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Validate checks the configuration and returns every problem it finds
+func (c *Config) Validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Port >= 1 && c.Port <= 65535, "port must be between 1 and 65535, got %d", c.Port)
+	check(c.LogFormat == "text" || c.LogFormat == "json", "log_format must be text or json, got %q", c.LogFormat)
+	check(c.TracingExporter == "none" || c.TracingExporter == "stdout" || c.TracingExporter == "otlp",
+		"tracing_exporter must be none, stdout or otlp, got %q", c.TracingExporter)
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive, got %s", c.ShutdownTimeout)
+	check(c.ReloadInterval >= 0, "reload_interval must not be negative, got %s", c.ReloadInterval)
+
+	if c.SolanaRpcEndpoint == "" {
+		errs = append(errs, fmt.Errorf("solana_rpc_endpoint is required (set SOLANA_RPC_ENDPOINT)"))
+	} else if err := checkURL(c.SolanaRpcEndpoint); err != nil {
+		errs = append(errs, fmt.Errorf("solana_rpc_endpoint: %w", err))
+	}
+	check(c.RpcTimeout > 0, "rpc_timeout must be positive, got %s", c.RpcTimeout)
+
+	if err := checkURL(c.ApifyActorURL); err != nil {
+		errs = append(errs, fmt.Errorf("apify_actor_url: %w", err))
+	}
+	check(c.ApifyTimeout > 0, "apify_timeout must be positive, got %s", c.ApifyTimeout)
+	check(c.FollowLimit >= 1, "follow_limit must be at least 1, got %d", c.FollowLimit)
+
+	check(c.DuneQueryID >= 1, "dune_query_id must be a positive query id, got %d", c.DuneQueryID)
+	check(c.AvoidListPath != "", "avoid_list_path is required")
+	check(c.AvoidListOverridesPath != "", "avoid_list_overrides_path is required")
+	check(c.AvoidListRefreshInterval >= 0, "avoid_list_refresh_interval must not be negative, got %s", c.AvoidListRefreshInterval)
+
+	check(c.GuessWorkers >= 1, "guess_workers must be at least 1, got %d", c.GuessWorkers)
+	check(c.GuessQueueSize >= 1, "guess_queue_size must be at least 1, got %d", c.GuessQueueSize)
+	check(c.GuessTimeout > 0, "guess_timeout must be positive, got %s", c.GuessTimeout)
+	check(c.MaxResults >= 1, "max_results must be at least 1, got %d", c.MaxResults)
+	check(c.ConfidentThreshold >= 0 && c.ConfidentThreshold <= 100,
+		"confident_threshold must be between 0 and 100, got %d", c.ConfidentThreshold)
+	check(c.UncertainThreshold >= 0 && c.UncertainThreshold <= 100,
+		"uncertain_threshold must be between 0 and 100, got %d", c.UncertainThreshold)
+	check(c.UncertainThreshold <= c.ConfidentThreshold,
+		"uncertain_threshold (%d) must not exceed confident_threshold (%d)", c.UncertainThreshold, c.ConfidentThreshold)
+
+	return errs
+}
+
+// checkURL reports whether value is an absolute http or https URL
+func checkURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// watchedFile is a file the Watcher checks for changes
+type watchedFile struct {
+	path     func() string
+	lastPath string
+	modTime  time.Time
+	size     int64
+}
+
+// watch is a set of files sharing one callback, so a change to several of them
+// at once runs it only once
+type watch struct {
+	files    []*watchedFile
+	onChange func()
+}
+
+// Watcher polls files for changes and reloads everything on SIGHUP
+type Watcher struct {
+	interval time.Duration
+	watches  []*watch
+	mutex    sync.Mutex
+}
+
+// NewWatcher creates a watcher that polls every interval; zero disables polling
+// so only SIGHUP triggers reloads
+func NewWatcher(interval time.Duration) *Watcher {
+	return &Watcher{interval: interval}
+}
+
+// Watch calls onChange when any of the files returned by paths is modified,
+// once per check however many of them changed. Each path is re-evaluated on
+// every check so a reload may point the watcher at another file.
+func (w *Watcher) Watch(onChange func(), paths ...func() string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	watch := &watch{onChange: onChange}
+	for _, path := range paths {
+		file := &watchedFile{path: path}
+		file.changed()
+		watch.files = append(watch.files, file)
+	}
+	w.watches = append(w.watches, watch)
+}
+
+// Run checks the watched files until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var ticks <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ticks:
+			w.check(false)
+		case <-hangups:
+			log.Info("Received SIGHUP, reloading configuration and avoid list")
+			w.check(true)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check runs the callbacks of watches with changed files, or of every watch when force is set
+func (w *Watcher) check(force bool) {
+	w.mutex.Lock()
+	watches := append([]*watch{}, w.watches...)
+	w.mutex.Unlock()
+
+	for _, watch := range watches {
+		// Every file is checked, so all their changes are recorded before the one callback
+		changed := false
+		for _, file := range watch.files {
+			if file.changed() {
+				changed = true
+			}
+		}
+		if changed || force {
+			watch.onChange()
+		}
+	}
+}
+
+// changed records the file's current state and reports whether it differs from
+// the last one seen. A file that now lives at a new path is not reported, since
+// whatever moved it has already loaded it.
+func (f *watchedFile) changed() bool {
+	path := f.path()
+	var modTime time.Time
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	changed := path == f.lastPath && (!modTime.Equal(f.modTime) || size != f.size)
+	f.lastPath, f.modTime, f.size = path, modTime, size
+	return changed && !modTime.IsZero()
+}
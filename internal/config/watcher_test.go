@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchRunsOnceForSeveralChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "config.yaml"), filepath.Join(dir, ".env")
+	for _, path := range []string{first, second} {
+		if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := 0
+	watcher := NewWatcher(0)
+	watcher.Watch(func() { calls++ }, func() string { return first }, func() string { return second })
+
+	watcher.check(false)
+	if calls != 0 {
+		t.Fatalf("ran %d times before anything changed", calls)
+	}
+
+	for _, path := range []string{first, second} {
+		if err := os.WriteFile(path, []byte("changed"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watcher.check(false)
+	if calls != 1 {
+		t.Fatalf("ran %d times for two changed files, want 1", calls)
+	}
+
+	watcher.check(false)
+	if calls != 1 {
+		t.Fatalf("ran again without a change")
+	}
+	watcher.check(true)
+	if calls != 2 {
+		t.Fatalf("forced check ran %d times in total, want 2", calls)
+	}
+}
@@ -0,0 +1,96 @@
+package domain
+
+import "strings"
+
+// AvoidCategory says why an address is on the avoid list. The known categories
+// are below; any other lower-case snake_case name may be used for new kinds of
+// entries and is reported as-is.
+type AvoidCategory string
+
+// Known avoid categories
+const (
+	CategoryPopularToken AvoidCategory = "popular_token" // token with too many holders
+	CategoryBusyWallet   AvoidCategory = "busy_wallet"   // wallet holding too many tokens
+	CategoryExchange     AvoidCategory = "exchange"
+	CategoryMarketMaker  AvoidCategory = "market_maker"
+	CategoryBot          AvoidCategory = "bot"
+	CategoryProgram      AvoidCategory = "program" // program or program-derived address
+	CategoryBurn         AvoidCategory = "burn"
+	CategoryLPVault      AvoidCategory = "lp_vault"
+	CategoryStablecoin   AvoidCategory = "stablecoin"
+	CategoryManual       AvoidCategory = "manual"
+)
+
+// avoidCategoryDescriptions explains the known categories to players
+var avoidCategoryDescriptions = map[AvoidCategory]string{
+	CategoryPopularToken: "token with too many holders (>100k)",
+	CategoryBusyWallet:   "wallet with too many tokens (>500)",
+	CategoryExchange:     "exchange wallet",
+	CategoryMarketMaker:  "market maker",
+	CategoryBot:          "bot",
+	CategoryProgram:      "program or program-owned account",
+	CategoryBurn:         "burn address",
+	CategoryLPVault:      "liquidity pool vault",
+	CategoryStablecoin:   "stablecoin",
+	CategoryManual:       "manually excluded",
+}
+
+// KnownAvoidCategories returns the built-in categories
+func KnownAvoidCategories() []AvoidCategory {
+	return []AvoidCategory{
+		CategoryPopularToken, CategoryBusyWallet, CategoryExchange, CategoryMarketMaker, CategoryBot,
+		CategoryProgram, CategoryBurn, CategoryLPVault, CategoryStablecoin, CategoryManual,
+	}
+}
+
+// Description explains the category; unknown categories are described by name
+func (c AvoidCategory) Description() string {
+	if description, ok := avoidCategoryDescriptions[c]; ok {
+		return description
+	}
+	return strings.ReplaceAll(string(c), "_", " ")
+}
+
+// Valid reports whether the category is a lower-case snake_case name
+func (c AvoidCategory) Valid() bool {
+	if c == "" || c[0] < 'a' || c[0] > 'z' {
+		return false
+	}
+	for _, r := range c {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// Avoid reason sources
+const (
+	AvoidSourceList     = "avoid_list" // an avoid list entry
+	AvoidSourceOverride = "override"   // a manual deny override
+)
+
+// AvoidReason explains why a candidate address was excluded
+type AvoidReason struct {
+	Address  string        `json:"address"`
+	Kind     string        `json:"kind,omitempty"` // "token" or "wallet", when known
+	Category AvoidCategory `json:"category"`
+	Source   string        `json:"source"` // AvoidSourceList or AvoidSourceOverride
+	Detail   string        `json:"detail"` // human readable explanation
+}
+
+// String returns the human readable explanation
+func (r AvoidReason) String() string {
+	return r.Detail
+}
+
+// KindOfType names an avoid list entry type: "token" for "t", "wallet" for "w"
+func KindOfType(entryType string) string {
+	switch entryType {
+	case "t":
+		return "token"
+	case "w":
+		return "wallet"
+	}
+	return ""
+}
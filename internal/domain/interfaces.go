@@ -1,44 +1,91 @@
 package domain
 
+import "context"
+
 // TwitterService defines the interface for Twitter API interactions
 type TwitterService interface {
 	// FetchFollowing fetches the accounts a user is following
-	FetchFollowing(username string, limit int, progressCallback ProgressCallback) ([]TwitterUser, error)
+	FetchFollowing(ctx context.Context, username string, limit int, progressCallback ProgressCallback) ([]TwitterUser, error)
 }
 
 // BlockchainService defines the interface for blockchain interactions
 type BlockchainService interface {
 	// GetWalletsForToken returns all wallet addresses that have interacted with a specific token
-	GetWalletsForToken(mintAddress string, progressCallback ProgressCallback) ([]string, error)
+	GetWalletsForToken(ctx context.Context, mintAddress string, progressCallback ProgressCallback) ([]string, error)
 	// GetTokenInfo gets information about a token from its mint address
 	GetTokenInfo(mintAddress string) (map[string]interface{}, error)
+	// ClearCache clears the token holder cache
+	ClearCache()
+	// ClearTokenCache removes one token from the holder cache, reporting whether it was cached
+	ClearTokenCache(mintAddress string) bool
+	// CacheStats returns statistics about the token holder cache
+	CacheStats() map[string]interface{}
 }
 
 // WalletGuesserService defines the interface for wallet guessing functionality
 type WalletGuesserService interface {
 	// GuessWallet tries to guess the wallet address for a given Twitter handle
-	GuessWallet(twitterHandle string, progressCallback ProgressCallback) (*WalletGuessResult, error)
+	GuessWallet(ctx context.Context, twitterHandle string, progressCallback ProgressCallback) (*WalletGuessResult, error)
 	// ClearCache clears the cache
 	ClearCache()
+	// ClearHandleCache removes the cached result for one Twitter handle, reporting whether it was cached
+	ClearHandleCache(twitterHandle string) bool
+	// ClearTokenCache removes cached data for one token mint, reporting whether it was cached
+	ClearTokenCache(mintAddress string) bool
 	// CacheStats returns statistics about the cache
 	CacheStats() map[string]interface{}
 }
 
 // AvoidListService defines the interface for the avoid list functionality
 type AvoidListService interface {
-	// ShouldAvoid checks if an address should be avoided
-	ShouldAvoid(address string) (bool, string)
-	// UpdateAvoidList updates the avoid list from the remote API
+	// ShouldAvoid checks if an address should be avoided and why
+	ShouldAvoid(address string) (bool, AvoidReason)
+	// UpdateAvoidList updates the avoid list from the remote API, unless it was updated within the past day
 	UpdateAvoidList() error
+	// ForceUpdateAvoidList updates the avoid list even if it was updated recently
+	ForceUpdateAvoidList() error
 	// GetAvoidListStats returns statistics about the avoid list
 	GetAvoidListStats() map[string]interface{}
+	// GetEntry returns the entry matching an address, if any
+	GetEntry(address string) (AvoidListEntry, bool)
+	// GetOverride returns the manual override matching an address, if any
+	GetOverride(address string) (AvoidListOverride, bool)
+	// SetOverride adds or replaces the manual override for an address or prefix and saves the overrides
+	SetOverride(override AvoidListOverride) error
+	// RemoveOverride removes the override stored under an address or prefix and saves the overrides, reporting whether it existed
+	RemoveOverride(key string) (bool, error)
+}
+
+// VerificationService defines the interface for wallet ownership verification
+type VerificationService interface {
+	// IssueChallenge creates a nonce the player of a game session must sign with the guessed wallet
+	IssueChallenge(sessionID string, twitterHandle string, address string) (*VerificationChallenge, error)
+	// VerifySignature checks a signature over a challenge previously issued to the same session
+	VerifySignature(sessionID string, nonce string, signature string) (*VerifiedGuess, error)
+	// GetVerifiedGuess returns the verified guess for a Twitter handle, if any
+	GetVerifiedGuess(twitterHandle string) (*VerifiedGuess, bool)
 }
 
-// WebSocketHandler defines the interface for WebSocket message handling
-type WebSocketHandler interface {
-	// HandleMessage handles an incoming WebSocket message
-	HandleMessage(message *WebSocketMessage, sendMessage func(message *WebSocketMessage) error) error
+// ProgressUpdate is one step of a running guess
+type ProgressUpdate struct {
+	Message string
+	// Exclusion is set when the step excluded a candidate because of the avoid list
+	Exclusion *AvoidReason
 }
 
 // ProgressCallback is a function type for reporting progress
-type ProgressCallback func(message string)
+type ProgressCallback func(update ProgressUpdate)
+
+// Report sends a progress message. It does nothing on a nil callback.
+func (p ProgressCallback) Report(message string) {
+	if p != nil {
+		p(ProgressUpdate{Message: message})
+	}
+}
+
+// ReportExclusion sends a progress message about a candidate the avoid list excluded
+func (p ProgressCallback) ReportExclusion(message string, reason AvoidReason) {
+	if p != nil {
+		p(ProgressUpdate{Message: message, Exclusion: &reason})
+	}
+}
@@ -1,5 +1,7 @@
 package domain
 
+import "time"
+
 // JinnState represents the state of the Jinn character
 type JinnState string
 
@@ -16,6 +18,7 @@ const (
 
 // GameState represents the current state of the game
 type GameState struct {
+	SessionID string    `json:"sessionId,omitempty"`
 	JinnState JinnState `json:"jinnState"`
 	Twitter   string    `json:"twitter,omitempty"`
 }
@@ -35,48 +38,96 @@ type WalletGuessResult struct {
 	Addresses     []string `json:"addresses"`
 	Sources       []string `json:"sources"`
 	Confidence    int      `json:"confidence"` // 0-100
+	// Excluded lists the candidates the avoid list excluded during the guess
+	Excluded []AvoidReason `json:"excluded,omitempty"`
 }
 
-// WebSocketMessage represents a WebSocket message structure
-type WebSocketMessage struct {
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
+// VerificationChallenge represents a pending wallet ownership challenge
+type VerificationChallenge struct {
+	Nonce         string
+	SessionID     string // the game session the challenge was issued to
+	Address       string
+	TwitterHandle string
+	Message       string
+	ExpiresAt     time.Time
 }
 
-// UserInputPayload represents the payload for USER_INPUT messages
-type UserInputPayload struct {
-	Twitter string `json:"twitter"`
+// VerifiedGuess represents a wallet guess whose ownership was proven by a signature
+type VerifiedGuess struct {
+	TwitterHandle string    `json:"twitterHandle"`
+	Address       string    `json:"address"`
+	VerifiedAt    time.Time `json:"verifiedAt"`
 }
 
-// ProgressMessage represents a progress update message
-type ProgressMessage struct {
-	Message string `json:"message"`
+// AvoidListEntry represents an entry in the avoid list. Address entries match
+// exactly one address; prefix entries match every address starting with Prefix.
+type AvoidListEntry struct {
+	Prefix   string        `json:"prefix,omitempty"`
+	Address  string        `json:"address,omitempty"`
+	Type     string        `json:"type"` // "t" for token, "w" for wallet
+	Category AvoidCategory `json:"category,omitempty"`
 }
 
-// JinnStatePayload represents a Jinn state update message payload
-type JinnStatePayload struct {
-	State   string `json:"state"`
-	Message string `json:"message"`
+// Key returns the address or prefix the entry is stored under
+func (e AvoidListEntry) Key() string {
+	if e.Address != "" {
+		return e.Address
+	}
+	return e.Prefix
 }
 
-// AvoidListEntry represents an entry in the avoid list
-type AvoidListEntry struct {
-	Prefix string `json:"prefix"`
-	Type   string `json:"type"` // "t" for token, "w" for wallet
+// CategoryOrDefault returns the entry's category. Entries without one, such as
+// those from Dune, are popular tokens or busy wallets by their type.
+func (e AvoidListEntry) CategoryOrDefault() AvoidCategory {
+	if e.Category != "" {
+		return e.Category
+	}
+	if e.Type == "t" {
+		return CategoryPopularToken
+	}
+	return CategoryBusyWallet
+}
+
+// Avoid list override actions
+const (
+	OverrideDeny  = "deny"  // always avoid the address
+	OverrideAllow = "allow" // never avoid the address, whatever the avoid list says
+)
+
+// AvoidListOverride is a manual decision that takes precedence over the avoid list
+type AvoidListOverride struct {
+	Prefix    string        `json:"prefix,omitempty"`
+	Address   string        `json:"address,omitempty"`
+	Action    string        `json:"action"`         // OverrideDeny or OverrideAllow
+	Type      string        `json:"type,omitempty"` // "t" for token, "w" for wallet, if known
+	Category  AvoidCategory `json:"category,omitempty"`
+	Reason    string        `json:"reason"`
+	Author    string        `json:"author"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// Key returns the address or prefix the override is stored under
+func (o AvoidListOverride) Key() string {
+	if o.Address != "" {
+		return o.Address
+	}
+	return o.Prefix
 }
 
+// CategoryOrDefault returns the override's category, manual unless set
+func (o AvoidListOverride) CategoryOrDefault() AvoidCategory {
+	if o.Category != "" {
+		return o.Category
+	}
+	return CategoryManual
+}
+
+// AvoidListZipped is a row of the Dune avoid list query
 type AvoidListZipped struct {
 	ZippedPrefix []string `json:"zip_prefix"`
 	ZippedType   []string `json:"zip_types"` // "t" for token, "w" for wallet
 }
 
-// AvoidListResponse represents the response from the avoid list API
-type AvoidListResponse struct {
-	Result struct {
-		Rows []AvoidListZipped `json:"rows"`
-	} `json:"result"`
-}
-
 // TokenInfo represents information about a token project
 type TokenInfo struct {
 	Symbol      string
@@ -0,0 +1,48 @@
+package game
+
+import (
+	"sync"
+
+	"wallet-guesser/internal/domain"
+)
+
+// maxExcluded caps the exclusions kept on a result, since a popular exchange
+// wallet can hold every token a player follows
+const maxExcluded = 100
+
+// exclusionCollector passes progress updates through while keeping the
+// distinct candidates the avoid list excluded, for the guess result
+type exclusionCollector struct {
+	next     domain.ProgressCallback
+	mutex    sync.Mutex
+	seen     map[string]bool
+	excluded []domain.AvoidReason
+}
+
+// newExclusionCollector wraps the progress callback of a guess
+func newExclusionCollector(next domain.ProgressCallback) *exclusionCollector {
+	return &exclusionCollector{next: next, seen: make(map[string]bool)}
+}
+
+// callback records exclusions and forwards every update
+func (c *exclusionCollector) callback(update domain.ProgressUpdate) {
+	if update.Exclusion != nil {
+		c.mutex.Lock()
+		if !c.seen[update.Exclusion.Address] && len(c.excluded) < maxExcluded {
+			c.seen[update.Exclusion.Address] = true
+			c.excluded = append(c.excluded, *update.Exclusion)
+		}
+		c.mutex.Unlock()
+	}
+	if c.next != nil {
+		c.next(update)
+	}
+}
+
+// reasons returns the exclusions in the order they happened
+func (c *exclusionCollector) reasons() []domain.AvoidReason {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]domain.AvoidReason(nil), c.excluded...)
+}
@@ -0,0 +1,71 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallet-guesser/internal/domain"
+)
+
+// resultCacheFile is the persisted form of the guess result cache
+type resultCacheFile struct {
+	SavedAt time.Time                            `json:"savedAt"`
+	Results map[string]*domain.WalletGuessResult `json:"results"`
+}
+
+// LoadResultCache fills the result cache from a file written by SaveResultCache.
+// A missing file leaves the cache empty. It returns the number of results loaded.
+func (wg *WalletGuesser) LoadResultCache(filePath string) (int, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read result cache: %w", err)
+	}
+
+	var fileData resultCacheFile
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal result cache: %w", err)
+	}
+
+	wg.cacheMutex.Lock()
+	defer wg.cacheMutex.Unlock()
+
+	for handle, result := range fileData.Results {
+		wg.resultCache[handle] = result
+	}
+	return len(fileData.Results), nil
+}
+
+// SaveResultCache atomically writes the result cache to a file, so guesses
+// survive a restart without new Apify scrapes. It returns the number of results saved.
+func (wg *WalletGuesser) SaveResultCache(filePath string) (int, error) {
+	wg.cacheMutex.RLock()
+	data, err := json.Marshal(resultCacheFile{
+		SavedAt: time.Now(),
+		Results: wg.resultCache,
+	})
+	count := len(wg.resultCache)
+	wg.cacheMutex.RUnlock()
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal result cache: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory for result cache: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated cache
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return 0, fmt.Errorf("failed to write result cache: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return 0, fmt.Errorf("failed to replace result cache: %w", err)
+	}
+	return count, nil
+}
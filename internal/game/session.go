@@ -0,0 +1,496 @@
+package game
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"wallet-guesser/internal/api/protocol"
+	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/logging"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ConfidentThreshold is the default minimum confidence for the Jinn to be confident in a guess
+	ConfidentThreshold = 70
+	// UncertainThreshold is the default minimum confidence for the Jinn to ask rather than give up
+	UncertainThreshold = 40
+	// maxBufferedEvents caps how many events a session keeps for replay after a reconnect
+	maxBufferedEvents = 500
+)
+
+// Guesser runs wallet guesses on behalf of a session
+type Guesser interface {
+	GuessWallet(ctx context.Context, twitterHandle string, progressCallback domain.ProgressCallback) (*domain.WalletGuessResult, error)
+}
+
+// EventType identifies the kind of event a session emits
+type EventType string
+
+// Session event types
+const (
+	EventStateChanged EventType = "state"
+	EventProgress     EventType = "progress"
+	EventResult       EventType = "result"
+)
+
+// Event describes something that happened in a session
+type Event struct {
+	Type    EventType
+	State   domain.JinnState
+	Message string
+	Result  *domain.WalletGuessResult
+	// Exclusion is set on progress events about a candidate the avoid list excluded
+	Exclusion *domain.AvoidReason
+	At        time.Time
+
+	// CorrelationID identifies the guess that produced a progress or result event
+	CorrelationID string
+}
+
+// StateChange records a single transition in a session's history
+type StateChange struct {
+	From    domain.JinnState `json:"from"`
+	To      domain.JinnState `json:"to"`
+	Message string           `json:"message"`
+	At      time.Time        `json:"at"`
+}
+
+// Thresholds decide how the Jinn reacts to a guess's confidence
+type Thresholds struct {
+	Confident int
+	Uncertain int
+}
+
+// DefaultThresholds are the thresholds used unless configured otherwise
+var DefaultThresholds = Thresholds{Confident: ConfidentThreshold, Uncertain: UncertainThreshold}
+
+// transitions lists the states reachable from each state. Any state may also
+// move to glitched when something goes wrong, or stay in place with a new message.
+// A guess may finish after the session glitched, so glitched leads to every
+// state a guess can end in.
+var transitions = map[domain.JinnState][]domain.JinnState{
+	domain.JinnStateIdle:      {domain.JinnStateAsking, domain.JinnStateThinking},
+	domain.JinnStateAsking:    {domain.JinnStateIdle, domain.JinnStateThinking, domain.JinnStateCorrect, domain.JinnStateWrong},
+	domain.JinnStateThinking:  {domain.JinnStateConfident, domain.JinnStateAsking, domain.JinnStateWrong},
+	domain.JinnStateConfident: {domain.JinnStateIdle, domain.JinnStateThinking, domain.JinnStateCorrect, domain.JinnStateWrong},
+	domain.JinnStateCorrect:   {domain.JinnStateIdle, domain.JinnStateThinking},
+	domain.JinnStateWrong:     {domain.JinnStateIdle, domain.JinnStateAsking, domain.JinnStateThinking, domain.JinnStateCorrect},
+	domain.JinnStateGlitched:  {domain.JinnStateIdle, domain.JinnStateAsking, domain.JinnStateThinking, domain.JinnStateConfident, domain.JinnStateWrong},
+}
+
+// CanTransition reports whether the state machine allows moving from one state to another
+func CanTransition(from domain.JinnState, to domain.JinnState) bool {
+	if from == to || to == domain.JinnStateGlitched {
+		return true
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Session is a single player's game, driving the Jinn through its states
+type Session struct {
+	ID string
+
+	// deliveryMutex serialises calls to listeners, which happen outside mutex
+	deliveryMutex  sync.Mutex
+	mutex          sync.Mutex
+	state          domain.JinnState
+	message        string
+	twitterHandle  string
+	guessing       bool
+	result         *domain.WalletGuessResult
+	history        []StateChange
+	events         []Event
+	outbox         []Event // emitted but not yet delivered to listeners
+	listeners      map[int]func(Event)
+	nextListenerID int
+	thresholds     Thresholds
+}
+
+// NewSession creates a new session in the idle state
+func NewSession(id string) *Session {
+	if id == "" {
+		id = NewSessionID()
+	}
+
+	return &Session{
+		ID:         id,
+		state:      domain.JinnStateIdle,
+		listeners:  make(map[int]func(Event)),
+		thresholds: DefaultThresholds,
+	}
+}
+
+// NewSessionID generates a random session identifier
+func NewSessionID() string {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		// crypto/rand never fails on supported platforms; fall back to the clock just in case
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(idBytes)
+}
+
+// Subscribe registers a listener for session events and returns a function that removes it.
+// Listeners are called in order, one event at a time, without the session locked.
+func (s *Session) Subscribe(listener func(Event)) func() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.subscribeLocked(listener)
+}
+
+// Attach replays the events buffered since the current game began to the listener
+// and then subscribes it, so a reconnecting client sees the full picture without gaps.
+// A session with nothing buffered replays its current state instead.
+func (s *Session) Attach(listener func(Event)) func() {
+	s.deliveryMutex.Lock()
+	defer s.deliveryMutex.Unlock()
+
+	// Events still waiting in the outbox go to the existing listeners only,
+	// since the replay already includes them
+	s.mutex.Lock()
+	pending, listeners := s.takeOutboxLocked()
+	replay := append([]Event(nil), s.events...)
+	if len(replay) == 0 {
+		replay = []Event{{Type: EventStateChanged, State: s.state, Message: s.message, At: time.Now()}}
+	}
+	unsubscribe := s.subscribeLocked(listener)
+	s.mutex.Unlock()
+
+	deliverEvents(pending, listeners)
+	deliverEvents(replay, []func(Event){listener})
+	return unsubscribe
+}
+
+// IsGuessing reports whether a wallet guess is currently running
+func (s *Session) IsGuessing() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.guessing
+}
+
+// State returns the current Jinn state
+func (s *Session) State() domain.JinnState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+// Snapshot returns the current game state for rendering
+func (s *Session) Snapshot() *domain.GameState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &domain.GameState{
+		JinnState: s.state,
+		Twitter:   s.twitterHandle,
+	}
+}
+
+// Result returns the last guess result, if any
+func (s *Session) Result() *domain.WalletGuessResult {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.result
+}
+
+// History returns a copy of the session's state transitions
+func (s *Session) History() []StateChange {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]StateChange(nil), s.history...)
+}
+
+// Transition moves the session to a new state if the state machine allows it.
+// While a guess runs only the guess moves the session, so it can always finish.
+func (s *Session) Transition(to domain.JinnState, message string) error {
+	defer s.deliver()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.guessing {
+		return errGuessInProgress()
+	}
+	return s.transitionLocked(to, message)
+}
+
+// Start resets the session for a new game
+func (s *Session) Start() error {
+	defer s.deliver()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.guessing {
+		return errGuessInProgress()
+	}
+	return s.beginRoundLocked(domain.JinnStateIdle, "I am the Crypto Jinn! I can divine your wallet address from your Twitter handle!")
+}
+
+// AskForHandle prompts the player for a Twitter handle
+func (s *Session) AskForHandle() error {
+	return s.Transition(domain.JinnStateAsking, "The Jinn needs a Twitter handle to divine the wallet address.")
+}
+
+// Fail moves the session to the glitched state. A session that is guessing is
+// left alone, since the guess will move it to its final state.
+func (s *Session) Fail(message string) {
+	defer s.deliver()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.guessing {
+		log.WithField(logging.FieldSession, s.ID).Warnf("Not glitching session while a guess is in progress: %s", message)
+		return
+	}
+	if err := s.transitionLocked(domain.JinnStateGlitched, message); err != nil {
+		log.WithField(logging.FieldSession, s.ID).Errorf("Failed to glitch session: %v", err)
+	}
+}
+
+// errInvalidTransition is returned for moves the state machine does not allow
+func errInvalidTransition(from domain.JinnState, to domain.JinnState) error {
+	return fmt.Errorf("invalid jinn state transition from %s to %s", from, to)
+}
+
+// errGuessInProgress is returned for requests a running guess rules out
+func errGuessInProgress() error {
+	return protocol.NewError(protocol.ErrorGuessInProgress, "the Jinn is still divining a wallet for this session")
+}
+
+// Guess runs the wallet guesser for a Twitter handle and drives the Jinn through
+// thinking to a final state based on the result. It blocks until the guess completes.
+func (s *Session) Guess(ctx context.Context, guesser Guesser, twitterHandle string) error {
+	if err := s.BeginGuess(twitterHandle); err != nil {
+		return err
+	}
+	return s.RunGuess(ctx, guesser)
+}
+
+// BeginGuess claims the session for a guess of a Twitter handle and moves the
+// Jinn to thinking. Only one claim can succeed until the guess finishes, so
+// callers that run the guess in the background can report a rejected claim
+// before they start it.
+func (s *Session) BeginGuess(twitterHandle string) error {
+	defer s.deliver()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.guessing {
+		return errGuessInProgress()
+	}
+	if err := s.beginRoundLocked(domain.JinnStateThinking, "Hmm... I'm consulting the mystical blockchain ledgers..."); err != nil {
+		return err
+	}
+	s.guessing = true
+	s.twitterHandle = twitterHandle
+	s.result = nil
+	return nil
+}
+
+// RunGuess runs the guess claimed by BeginGuess and moves the Jinn to a final
+// state based on the result. It blocks until the guess completes.
+func (s *Session) RunGuess(ctx context.Context, guesser Guesser) error {
+	s.mutex.Lock()
+	guessing, twitterHandle := s.guessing, s.twitterHandle
+	s.mutex.Unlock()
+	if !guessing {
+		return fmt.Errorf("session %s has no guess to run", s.ID)
+	}
+
+	ctx = logging.WithFields(logging.EnsureCorrelationID(ctx), log.Fields{
+		logging.FieldSession: s.ID,
+		logging.FieldHandle:  twitterHandle,
+	})
+	correlationID := logging.CorrelationID(ctx)
+	logger := logging.FromContext(ctx)
+
+	progressCallback := func(update domain.ProgressUpdate) {
+		if update.Exclusion != nil {
+			logger.Debugf("Progress: %s", update.Message)
+		} else {
+			logger.Infof("Progress: %s", update.Message)
+		}
+		s.mutex.Lock()
+		s.emitLocked(Event{Type: EventProgress, State: s.state, Message: update.Message, Exclusion: update.Exclusion, CorrelationID: correlationID})
+		s.mutex.Unlock()
+		s.deliver()
+	}
+
+	result, err := guesser.GuessWallet(ctx, twitterHandle, progressCallback)
+
+	defer s.deliver()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.guessing = false
+
+	if err != nil {
+		if transitionErr := s.transitionLocked(domain.JinnStateWrong, "The crypto spirits are not cooperating today. Please try again later."); transitionErr != nil {
+			logger.Errorf("Failed to end the failed guess: %v", transitionErr)
+		}
+		return fmt.Errorf("error guessing wallet: %w", err)
+	}
+
+	s.result = result
+	if len(result.Addresses) == 0 {
+		return s.transitionLocked(domain.JinnStateWrong, "I could not divine any wallet addresses for this Twitter handle.")
+	}
+
+	s.emitLocked(Event{Type: EventResult, State: s.state, Result: result, CorrelationID: correlationID})
+
+	switch {
+	case result.Confidence >= s.thresholds.Confident:
+		return s.transitionLocked(domain.JinnStateConfident, "Aha! I sense strong wallet energy from this Twitter handle!")
+	case result.Confidence >= s.thresholds.Uncertain:
+		return s.transitionLocked(domain.JinnStateAsking, "I sense some wallet energy, but I'm not entirely sure...")
+	default:
+		return s.transitionLocked(domain.JinnStateWrong, "The blockchain spirits have whispered some addresses, but I'm uncertain...")
+	}
+}
+
+// SetThresholds changes the confidence thresholds the session judges guesses by
+func (s *Session) SetThresholds(thresholds Thresholds) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.thresholds = thresholds
+}
+
+// HasGuessed reports whether address is among the addresses in the last result
+func (s *Session) HasGuessed(address string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.result == nil {
+		return false
+	}
+	for _, candidate := range s.result.Addresses {
+		if candidate == address {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfirmVerified marks the guess as correct once wallet ownership has been
+// proven. A proof for an address this session did not guess is a client error
+// and leaves the state unchanged.
+func (s *Session) ConfirmVerified(verified *domain.VerifiedGuess) error {
+	if !s.HasGuessed(verified.Address) {
+		return protocol.NewError(protocol.ErrorVerificationFailed, "the Jinn did not divine address %s for you", verified.Address)
+	}
+	return s.Transition(domain.JinnStateCorrect, "Behold! The wallet's own signature proves I divined correctly!")
+}
+
+// RejectVerification marks the guess as wrong after a failed ownership proof
+func (s *Session) RejectVerification() error {
+	return s.Transition(domain.JinnStateWrong, "That signature does not bear the mark of the wallet I divined...")
+}
+
+// subscribeLocked registers a listener. The caller must hold the mutex.
+func (s *Session) subscribeLocked(listener func(Event)) func() {
+	id := s.nextListenerID
+	s.nextListenerID++
+	s.listeners[id] = listener
+
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		delete(s.listeners, id)
+	}
+}
+
+// beginRoundLocked clears the replay buffer and applies the transition that
+// starts a new game or guess. A rejected transition keeps the buffer, so a
+// reconnecting client still sees the round it was in. The caller must hold the mutex.
+func (s *Session) beginRoundLocked(to domain.JinnState, message string) error {
+	if !CanTransition(s.state, to) {
+		return errInvalidTransition(s.state, to)
+	}
+	s.events = nil
+	return s.transitionLocked(to, message)
+}
+
+// transitionLocked validates and applies a transition. The caller must hold the mutex.
+func (s *Session) transitionLocked(to domain.JinnState, message string) error {
+	from := s.state
+	if !CanTransition(from, to) {
+		return errInvalidTransition(from, to)
+	}
+
+	change := StateChange{From: from, To: to, Message: message, At: time.Now()}
+	s.state = to
+	s.message = message
+	s.history = append(s.history, change)
+
+	s.emitLocked(Event{Type: EventStateChanged, State: to, Message: message, At: change.At})
+	return nil
+}
+
+// emitLocked buffers an event and queues it for the listeners. The caller must
+// hold the mutex and call deliver once it has released it.
+func (s *Session) emitLocked(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	// Buffer the event for replay, dropping the oldest once the buffer is full
+	if len(s.events) >= maxBufferedEvents {
+		s.events = s.events[1:]
+	}
+	s.events = append(s.events, event)
+	s.outbox = append(s.outbox, event)
+}
+
+// deliver hands queued events to the listeners without holding the mutex, so a
+// slow listener cannot stall the session. Deliveries happen one at a time, in
+// the order the events were emitted.
+func (s *Session) deliver() {
+	s.deliveryMutex.Lock()
+	defer s.deliveryMutex.Unlock()
+
+	s.mutex.Lock()
+	events, listeners := s.takeOutboxLocked()
+	s.mutex.Unlock()
+
+	deliverEvents(events, listeners)
+}
+
+// takeOutboxLocked empties the outbox and copies the listeners it is for, in
+// subscription order. The caller must hold the mutex.
+func (s *Session) takeOutboxLocked() ([]Event, []func(Event)) {
+	events := s.outbox
+	s.outbox = nil
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int, 0, len(s.listeners))
+	for id := range s.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	listeners := make([]func(Event), 0, len(ids))
+	for _, id := range ids {
+		listeners = append(listeners, s.listeners[id])
+	}
+	return events, listeners
+}
+
+// deliverEvents calls each listener with each event
+func deliverEvents(events []Event, listeners []func(Event)) {
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
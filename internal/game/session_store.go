@@ -0,0 +1,116 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSessionTTL is how long a session is kept after its last connection detaches
+	DefaultSessionTTL = 10 * time.Minute
+)
+
+// storedSession tracks how many connections are attached to a session
+type storedSession struct {
+	session    *Session
+	attached   int
+	detachedAt time.Time
+}
+
+// SessionStore keeps sessions alive across reconnects so clients can resume them
+type SessionStore struct {
+	ttl        time.Duration
+	thresholds Thresholds
+	sessions   map[string]*storedSession
+	mutex      sync.Mutex
+}
+
+// NewSessionStore creates a new session store
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+
+	return &SessionStore{
+		ttl:        ttl,
+		thresholds: DefaultThresholds,
+		sessions:   make(map[string]*storedSession),
+	}
+}
+
+// SetThresholds sets the confidence thresholds of new and existing sessions.
+// A running guess is judged by the thresholds in force when it completes.
+func (st *SessionStore) SetThresholds(thresholds Thresholds) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.thresholds = thresholds
+	for _, stored := range st.sessions {
+		stored.session.SetThresholds(thresholds)
+	}
+}
+
+// Create starts a new session attached to one connection
+func (st *SessionStore) Create() *Session {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.pruneLocked()
+
+	session := NewSession("")
+	session.thresholds = st.thresholds
+	st.sessions[session.ID] = &storedSession{session: session, attached: 1}
+	return session
+}
+
+// Resume attaches another connection to an existing session
+func (st *SessionStore) Resume(id string) (*Session, bool) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	st.pruneLocked()
+
+	stored, ok := st.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	stored.attached++
+	return stored.session, true
+}
+
+// Detach records that a connection has left a session. The session stays
+// resumable until it has been detached for longer than the store's TTL.
+func (st *SessionStore) Detach(id string) {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+
+	stored, ok := st.sessions[id]
+	if !ok {
+		return
+	}
+	if stored.attached > 0 {
+		stored.attached--
+	}
+	if stored.attached == 0 {
+		stored.detachedAt = time.Now()
+	}
+}
+
+// Len returns the number of sessions being kept
+func (st *SessionStore) Len() int {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return len(st.sessions)
+}
+
+// pruneLocked removes sessions nobody has been attached to for longer than the TTL.
+// Sessions that are still guessing are kept so their result can be collected.
+// The caller must hold the mutex.
+func (st *SessionStore) pruneLocked() {
+	cutoff := time.Now().Add(-st.ttl)
+	for id, stored := range st.sessions {
+		if stored.attached == 0 && stored.detachedAt.Before(cutoff) && !stored.session.IsGuessing() {
+			delete(st.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet-guesser/internal/api/protocol"
+	"wallet-guesser/internal/domain"
+)
+
+// blockingGuesser holds each guess until release is closed
+type blockingGuesser struct {
+	started chan struct{}
+	release chan struct{}
+	result  *domain.WalletGuessResult
+	err     error
+}
+
+func newBlockingGuesser(result *domain.WalletGuessResult, err error) *blockingGuesser {
+	return &blockingGuesser{started: make(chan struct{}), release: make(chan struct{}), result: result, err: err}
+}
+
+func (g *blockingGuesser) GuessWallet(ctx context.Context, _ string, progressCallback domain.ProgressCallback) (*domain.WalletGuessResult, error) {
+	close(g.started)
+	progressCallback.Report("working")
+	<-g.release
+	return g.result, g.err
+}
+
+// startGuess runs a guess in the background and waits until the guesser is called
+func startGuess(t *testing.T, session *Session, guesser *blockingGuesser) <-chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Guess(context.Background(), guesser, "alice")
+	}()
+	select {
+	case <-guesser.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("guess did not start")
+	}
+	return done
+}
+
+func finishGuess(t *testing.T, guesser *blockingGuesser, done <-chan error) error {
+	t.Helper()
+	close(guesser.release)
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("guess did not finish")
+		return nil
+	}
+}
+
+func requireGuessInProgress(t *testing.T, err error) {
+	t.Helper()
+	var protocolErr *protocol.Error
+	if !errors.As(err, &protocolErr) || protocolErr.Code != protocol.ErrorGuessInProgress {
+		t.Fatalf("expected a %s error, got %v", protocol.ErrorGuessInProgress, err)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to domain.JinnState
+		want     bool
+	}{
+		{domain.JinnStateIdle, domain.JinnStateThinking, true},
+		{domain.JinnStateIdle, domain.JinnStateAsking, true},
+		{domain.JinnStateThinking, domain.JinnStateConfident, true},
+		{domain.JinnStateThinking, domain.JinnStateAsking, true},
+		{domain.JinnStateThinking, domain.JinnStateWrong, true},
+		{domain.JinnStateConfident, domain.JinnStateCorrect, true},
+		{domain.JinnStateGlitched, domain.JinnStateConfident, true},
+		{domain.JinnStateGlitched, domain.JinnStateWrong, true},
+		{domain.JinnStateCorrect, domain.JinnStateCorrect, true},
+		{domain.JinnStateCorrect, domain.JinnStateGlitched, true},
+		{domain.JinnStateIdle, domain.JinnStateConfident, false},
+		{domain.JinnStateIdle, domain.JinnStateCorrect, false},
+		{domain.JinnStateThinking, domain.JinnStateIdle, false},
+		{domain.JinnStateThinking, domain.JinnStateCorrect, false},
+		{domain.JinnStateAsking, domain.JinnStateConfident, false},
+		{domain.JinnStateCorrect, domain.JinnStateWrong, false},
+		{domain.JinnStateGlitched, domain.JinnStateCorrect, false},
+	}
+	for _, test := range tests {
+		if got := CanTransition(test.from, test.to); got != test.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestTransitionRejectsInvalidMoves(t *testing.T) {
+	session := NewSession("test")
+	if err := session.Transition(domain.JinnStateCorrect, ""); err == nil {
+		t.Fatal("expected idle to correct to be rejected")
+	}
+	if state := session.State(); state != domain.JinnStateIdle {
+		t.Fatalf("state = %s, want idle", state)
+	}
+	if err := session.AskForHandle(); err != nil {
+		t.Fatalf("AskForHandle: %v", err)
+	}
+	if history := session.History(); len(history) != 1 || history[0].To != domain.JinnStateAsking {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
+
+func TestGuessDrivesFinalState(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *domain.WalletGuessResult
+		err    error
+		want   domain.JinnState
+	}{
+		{"confident", &domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 90}, nil, domain.JinnStateConfident},
+		{"uncertain", &domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 50}, nil, domain.JinnStateAsking},
+		{"low confidence", &domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 10}, nil, domain.JinnStateWrong},
+		{"no addresses", &domain.WalletGuessResult{}, nil, domain.JinnStateWrong},
+		{"failed", nil, errors.New("boom"), domain.JinnStateWrong},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session := NewSession("test")
+			guesser := newBlockingGuesser(test.result, test.err)
+			done := startGuess(t, session, guesser)
+			err := finishGuess(t, guesser, done)
+			if (err != nil) != (test.err != nil) {
+				t.Fatalf("Guess error = %v, want error %v", err, test.err != nil)
+			}
+			if state := session.State(); state != test.want {
+				t.Fatalf("state = %s, want %s", state, test.want)
+			}
+		})
+	}
+}
+
+func TestGuessInProgressBlocksOtherTransitions(t *testing.T) {
+	session := NewSession("test")
+	guesser := newBlockingGuesser(&domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 90}, nil)
+	done := startGuess(t, session, guesser)
+
+	requireGuessInProgress(t, session.Start())
+	requireGuessInProgress(t, session.AskForHandle())
+	requireGuessInProgress(t, session.RejectVerification())
+	requireGuessInProgress(t, session.Guess(context.Background(), guesser, "bob"))
+	session.Fail("something went wrong")
+	if state := session.State(); state != domain.JinnStateThinking {
+		t.Fatalf("state during guess = %s, want thinking", state)
+	}
+
+	if err := finishGuess(t, guesser, done); err != nil {
+		t.Fatalf("Guess: %v", err)
+	}
+	if state := session.State(); state != domain.JinnStateConfident {
+		t.Fatalf("state = %s, want confident", state)
+	}
+}
+
+func TestGuessFinishesAfterGlitch(t *testing.T) {
+	session := NewSession("test")
+	if err := session.Transition(domain.JinnStateGlitched, "glitch"); err != nil {
+		t.Fatalf("Transition: %v", err)
+	}
+	guesser := newBlockingGuesser(&domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 90}, nil)
+	done := startGuess(t, session, guesser)
+	if err := finishGuess(t, guesser, done); err != nil {
+		t.Fatalf("Guess: %v", err)
+	}
+	if state := session.State(); state != domain.JinnStateConfident {
+		t.Fatalf("state = %s, want confident", state)
+	}
+}
+
+func TestListenersRunWithoutTheSessionLocked(t *testing.T) {
+	session := NewSession("test")
+	var states []domain.JinnState
+	session.Subscribe(func(event Event) {
+		// Calling back into the session would deadlock if the mutex were held
+		if event.Type == EventStateChanged {
+			states = append(states, session.State())
+		}
+	})
+
+	guesser := newBlockingGuesser(&domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 90}, nil)
+	done := startGuess(t, session, guesser)
+	if err := finishGuess(t, guesser, done); err != nil {
+		t.Fatalf("Guess: %v", err)
+	}
+
+	if len(states) != 2 || states[1] != domain.JinnStateConfident {
+		t.Fatalf("listener saw states %v", states)
+	}
+}
+
+func TestAttachReplaysBufferedEvents(t *testing.T) {
+	session := NewSession("test")
+	guesser := newBlockingGuesser(&domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 90}, nil)
+	done := startGuess(t, session, guesser)
+	if err := finishGuess(t, guesser, done); err != nil {
+		t.Fatalf("Guess: %v", err)
+	}
+
+	var replayed []EventType
+	session.Attach(func(event Event) {
+		replayed = append(replayed, event.Type)
+	})
+	want := []EventType{EventStateChanged, EventProgress, EventResult, EventStateChanged}
+	if len(replayed) != len(want) {
+		t.Fatalf("replayed %v, want %v", replayed, want)
+	}
+	for i := range want {
+		if replayed[i] != want[i] {
+			t.Fatalf("replayed %v, want %v", replayed, want)
+		}
+	}
+}
+
+func TestConfirmVerifiedRejectsUnguessedAddress(t *testing.T) {
+	session := NewSession("test")
+	guesser := newBlockingGuesser(&domain.WalletGuessResult{Addresses: []string{"a"}, Confidence: 90}, nil)
+	done := startGuess(t, session, guesser)
+	if err := finishGuess(t, guesser, done); err != nil {
+		t.Fatalf("Guess: %v", err)
+	}
+
+	err := session.ConfirmVerified(&domain.VerifiedGuess{Address: "b"})
+	var protocolErr *protocol.Error
+	if !errors.As(err, &protocolErr) || protocolErr.Code != protocol.ErrorVerificationFailed {
+		t.Fatalf("expected a %s error, got %v", protocol.ErrorVerificationFailed, err)
+	}
+	if state := session.State(); state != domain.JinnStateConfident {
+		t.Fatalf("state = %s, want confident", state)
+	}
+
+	if err := session.ConfirmVerified(&domain.VerifiedGuess{Address: "a"}); err != nil {
+		t.Fatalf("ConfirmVerified: %v", err)
+	}
+	if state := session.State(); state != domain.JinnStateCorrect {
+		t.Fatalf("state = %s, want correct", state)
+	}
+}
+
+func TestRejectedRoundKeepsReplayBuffer(t *testing.T) {
+	session := NewSession("test")
+	if err := session.AskForHandle(); err != nil {
+		t.Fatalf("AskForHandle: %v", err)
+	}
+	if err := session.Transition(domain.JinnStateThinking, "thinking"); err != nil {
+		t.Fatalf("Transition: %v", err)
+	}
+
+	// Thinking cannot move back to idle, so Start is rejected and must not drop the buffered events
+	if err := session.Start(); err == nil {
+		t.Fatal("expected Start from thinking to be rejected")
+	}
+
+	var replayed int
+	session.Attach(func(Event) { replayed++ })
+	if replayed != 2 {
+		t.Fatalf("replayed %d events, want 2", replayed)
+	}
+}
@@ -1,14 +1,26 @@
 package game
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/metrics"
+	"wallet-guesser/internal/tracing"
 
-	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultFollowLimit is the number of followed accounts fetched per guess
+	DefaultFollowLimit = 500
+	// DefaultMaxResults is the maximum number of addresses returned per guess
+	DefaultMaxResults = 5
 )
 
 // WalletGuesser implements domain.WalletGuesserService
@@ -16,48 +28,105 @@ type WalletGuesser struct {
 	twitterClient    domain.TwitterService
 	blockchainClient domain.BlockchainService
 	avoidListService domain.AvoidListService
+	followLimit      int
+	maxResults       int
 	cacheMutex       sync.RWMutex
 	resultCache      map[string]*domain.WalletGuessResult
 	tokenCache       map[string]domain.TokenInfo
 }
 
+// WalletGuesserOption is a functional option for configuring the WalletGuesser
+type WalletGuesserOption func(*WalletGuesser)
+
+// WithFollowLimit sets how many followed accounts are fetched per guess
+func WithFollowLimit(limit int) WalletGuesserOption {
+	return func(wg *WalletGuesser) {
+		wg.followLimit = limit
+	}
+}
+
+// WithMaxResults sets the maximum number of addresses returned per guess
+func WithMaxResults(maxResults int) WalletGuesserOption {
+	return func(wg *WalletGuesser) {
+		wg.maxResults = maxResults
+	}
+}
+
 // NewWalletGuesser creates a new WalletGuesser
 func NewWalletGuesser(
 	twitterClient domain.TwitterService,
 	blockchainClient domain.BlockchainService,
 	avoidListService domain.AvoidListService,
+	options ...WalletGuesserOption,
 ) *WalletGuesser {
-	return &WalletGuesser{
+	wg := &WalletGuesser{
 		twitterClient:    twitterClient,
 		blockchainClient: blockchainClient,
 		avoidListService: avoidListService,
+		followLimit:      DefaultFollowLimit,
+		maxResults:       DefaultMaxResults,
 		resultCache:      make(map[string]*domain.WalletGuessResult),
 		tokenCache:       make(map[string]domain.TokenInfo),
 	}
+
+	for _, option := range options {
+		option(wg)
+	}
+
+	return wg
 }
 
 // GuessWallet tries to guess the wallet address for a given Twitter handle
-func (wg *WalletGuesser) GuessWallet(twitterHandle string, progressCallback domain.ProgressCallback) (*domain.WalletGuessResult, error) {
+func (wg *WalletGuesser) GuessWallet(ctx context.Context, twitterHandle string, progressCallback domain.ProgressCallback) (*domain.WalletGuessResult, error) {
+	ctx, span := tracing.Start(ctx, "game.GuessWallet", attribute.String("twitter.handle", twitterHandle))
+	ctx = logging.WithField(logging.EnsureCorrelationID(ctx), logging.FieldHandle, twitterHandle)
+	start := time.Now()
+	result, cached, err := wg.guessWallet(ctx, twitterHandle, progressCallback)
+	tracing.End(span, err)
+
+	entry := logging.FromContext(ctx).WithField(logging.FieldDuration, logging.Duration(start))
+	switch {
+	case err != nil:
+		entry.Errorf("Guess failed: %v", err)
+		metrics.ObserveDuration(metrics.GuessDuration, metrics.OutcomeError, start)
+		return nil, err
+	case cached:
+		entry.Info("Guess served from cache")
+		metrics.ObserveDuration(metrics.GuessDuration, metrics.OutcomeCached, start)
+	default:
+		entry.WithField("confidence", result.Confidence).Infof("Guess found %d addresses", len(result.Addresses))
+		metrics.ObserveDuration(metrics.GuessDuration, metrics.OutcomeSuccess, start)
+		if len(result.Addresses) > 0 {
+			metrics.ConfidenceScore.Observe(float64(result.Confidence))
+		}
+	}
+	return result, nil
+}
+
+// guessWallet runs the guess for GuessWallet, reporting whether the result came from the cache
+func (wg *WalletGuesser) guessWallet(ctx context.Context, twitterHandle string, progressCallback domain.ProgressCallback) (*domain.WalletGuessResult, bool, error) {
 	// Clean the Twitter handle (remove @ if present)
 	twitterHandle = strings.TrimPrefix(twitterHandle, "@")
 
 	// Check cache first
 	wg.cacheMutex.RLock()
-	if result, found := wg.resultCache[twitterHandle]; found {
-		wg.cacheMutex.RUnlock()
-		if progressCallback != nil {
-			progressCallback(fmt.Sprintf("Using cached results for @%s", twitterHandle))
-		}
-		return result, nil
-	}
+	result, found := wg.resultCache[twitterHandle]
 	wg.cacheMutex.RUnlock()
-
-	if progressCallback != nil {
-		progressCallback(fmt.Sprintf("The Jinn is analyzing @%s's Twitter profile...", twitterHandle))
+	metrics.CacheLookup("guess_result", found)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("guess.cached", found))
+	if found {
+		progressCallback.Report(fmt.Sprintf("Using cached results for @%s", twitterHandle))
+		return result, true, nil
 	}
 
+	progressCallback.Report(fmt.Sprintf("The Jinn is analyzing @%s's Twitter profile...", twitterHandle))
+
+	// Keep the candidates the avoid list excludes along the way for the result
+	exclusions := newExclusionCollector(progressCallback)
+	progressCallback = exclusions.callback
+
 	// Initialize the result
-	result := &domain.WalletGuessResult{
+	result = &domain.WalletGuessResult{
 		TwitterHandle: twitterHandle,
 		Addresses:     []string{},
 		Sources:       []string{},
@@ -65,50 +134,86 @@ func (wg *WalletGuesser) GuessWallet(twitterHandle string, progressCallback doma
 	}
 
 	// Fetch accounts the user follows
-	following, err := wg.twitterClient.FetchFollowing(twitterHandle, 500, progressCallback)
+	following, err := wg.twitterClient.FetchFollowing(ctx, twitterHandle, wg.followLimit, progressCallback)
 	if err != nil {
-		log.Errorf("Error fetching following for %s: %v", twitterHandle, err)
-		return nil, fmt.Errorf("failed to fetch accounts followed by @%s: %w", twitterHandle, err)
+		return nil, false, fmt.Errorf("failed to fetch accounts followed by @%s: %w", twitterHandle, err)
 	}
 
 	// Extract token addresses and process them
 	potentialTokens := wg.extractPotentialTokens(following, progressCallback)
 	if len(potentialTokens) == 0 {
+		result.Excluded = exclusions.reasons()
+
 		// Cache the empty result to avoid repeated lookups
 		wg.cacheMutex.Lock()
 		wg.resultCache[twitterHandle] = result
 		wg.cacheMutex.Unlock()
-		return result, nil
+		return result, false, nil
 	}
 
 	// Find wallet addresses for each token
-	rankedWallets := wg.findWalletsForTokens(potentialTokens, progressCallback)
+	holdersCtx, holdersSpan := tracing.Start(ctx, "game.findWalletsForTokens", attribute.Int("guess.tokens", len(potentialTokens)))
+	rankedWallets, err := wg.findWalletsForTokens(holdersCtx, potentialTokens, progressCallback)
+	tracing.End(holdersSpan, err)
+	if err != nil {
+		return nil, false, err
+	}
 
 	// Process the ranked wallets into the result
+	_, scoringSpan := tracing.Start(ctx, "game.processRankedWallets", attribute.Int("guess.wallets", len(rankedWallets)))
 	result = wg.processRankedWallets(twitterHandle, rankedWallets, potentialTokens)
+	result.Excluded = exclusions.reasons()
+	scoringSpan.SetAttributes(
+		attribute.Int("guess.addresses", len(result.Addresses)),
+		attribute.Int("guess.confidence", result.Confidence),
+	)
+	tracing.End(scoringSpan, nil)
 
 	// Cache the result
 	wg.cacheMutex.Lock()
 	wg.resultCache[twitterHandle] = result
 	wg.cacheMutex.Unlock()
 
-	if progressCallback != nil {
-		if len(result.Addresses) > 0 {
-			progressCallback(fmt.Sprintf("Analysis complete. Found %d potential wallet addresses.", len(result.Addresses)))
-		} else {
-			progressCallback("Analysis complete. No strong wallet matches found.")
-		}
+	if len(result.Addresses) > 0 {
+		progressCallback.Report(fmt.Sprintf("Analysis complete. Found %d potential wallet addresses.", len(result.Addresses)))
+	} else {
+		progressCallback.Report("Analysis complete. No strong wallet matches found.")
 	}
 
-	return result, nil
+	return result, false, nil
 }
 
-// ClearCache clears the cache
+// ClearCache clears the cache, including the blockchain client's token holder cache
 func (wg *WalletGuesser) ClearCache() {
 	wg.cacheMutex.Lock()
-	defer wg.cacheMutex.Unlock()
 	wg.resultCache = make(map[string]*domain.WalletGuessResult)
 	wg.tokenCache = make(map[string]domain.TokenInfo)
+	wg.cacheMutex.Unlock()
+
+	wg.blockchainClient.ClearCache()
+}
+
+// ClearHandleCache removes the cached result for one Twitter handle
+func (wg *WalletGuesser) ClearHandleCache(twitterHandle string) bool {
+	twitterHandle = strings.TrimPrefix(twitterHandle, "@")
+
+	wg.cacheMutex.Lock()
+	defer wg.cacheMutex.Unlock()
+
+	_, found := wg.resultCache[twitterHandle]
+	delete(wg.resultCache, twitterHandle)
+	return found
+}
+
+// ClearTokenCache removes cached data for one token mint
+func (wg *WalletGuesser) ClearTokenCache(mintAddress string) bool {
+	wg.cacheMutex.Lock()
+	_, found := wg.tokenCache[mintAddress]
+	delete(wg.tokenCache, mintAddress)
+	wg.cacheMutex.Unlock()
+
+	clearedHolders := wg.blockchainClient.ClearTokenCache(mintAddress)
+	return found || clearedHolders
 }
 
 // CacheStats returns statistics about the cache
@@ -119,6 +224,7 @@ func (wg *WalletGuesser) CacheStats() map[string]interface{} {
 	return map[string]interface{}{
 		"resultsSize": len(wg.resultCache),
 		"tokensSize":  len(wg.tokenCache),
+		"blockchain":  wg.blockchainClient.CacheStats(),
 		"lastUpdated": time.Now().Format(time.RFC3339),
 	}
 }
@@ -1,12 +1,13 @@
 package game
 
 import (
+	"context"
 	"fmt"
-	log "github.com/sirupsen/logrus"
 	"sort"
 	"strings"
 
 	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/logging"
 )
 
 // TokenWithSource pairs a token address with its source (Twitter handle)
@@ -23,9 +24,7 @@ type WalletScore struct {
 
 // extractPotentialTokens extracts potential token addresses from followed accounts
 func (wg *WalletGuesser) extractPotentialTokens(following []domain.TwitterUser, progressCallback domain.ProgressCallback) []TokenWithSource {
-	if progressCallback != nil {
-		progressCallback(fmt.Sprintf("Analyzing %d accounts to identify token projects...", len(following)))
-	}
+	progressCallback.Report(fmt.Sprintf("Analyzing %d accounts to identify token projects...", len(following)))
 
 	var tokenSources []TokenWithSource
 
@@ -35,9 +34,7 @@ func (wg *WalletGuesser) extractPotentialTokens(following []domain.TwitterUser,
 			// Check if the token should be avoided
 			if wg.avoidListService != nil {
 				if shouldAvoid, reason := wg.avoidListService.ShouldAvoid(mint); shouldAvoid {
-					if progressCallback != nil {
-						progressCallback(fmt.Sprintf("Skipping token %s from @%s: %s", mint, user.Username, reason))
-					}
+					progressCallback.ReportExclusion(fmt.Sprintf("Skipping token %s from @%s: %s", mint, user.Username, reason), reason)
 					continue
 				}
 			}
@@ -47,16 +44,14 @@ func (wg *WalletGuesser) extractPotentialTokens(following []domain.TwitterUser,
 				Source:      fmt.Sprintf("@%s", user.Username),
 			})
 
-			if progressCallback != nil {
-				progressCallback(fmt.Sprintf("Found potential token mint address from @%s", user.Username))
-			}
+			progressCallback.Report(fmt.Sprintf("Found potential token mint address from @%s", user.Username))
 		}
 	}
 
-	if len(tokenSources) == 0 && progressCallback != nil {
-		progressCallback("No token projects identified from followed accounts")
-	} else if progressCallback != nil {
-		progressCallback(fmt.Sprintf("Found %d potential token projects to analyze", len(tokenSources)))
+	if len(tokenSources) == 0 {
+		progressCallback.Report("No token projects identified from followed accounts")
+	} else {
+		progressCallback.Report(fmt.Sprintf("Found %d potential token projects to analyze", len(tokenSources)))
 	}
 
 	return tokenSources
@@ -64,24 +59,28 @@ func (wg *WalletGuesser) extractPotentialTokens(following []domain.TwitterUser,
 
 // findWalletsForTokens gets wallets that have interacted with the given tokens
 // Optimized to track wallet-to-token relationships and reduce avoid-list checks
-func (wg *WalletGuesser) findWalletsForTokens(tokenSources []TokenWithSource, progressCallback domain.ProgressCallback) []WalletScore {
+func (wg *WalletGuesser) findWalletsForTokens(ctx context.Context, tokenSources []TokenWithSource, progressCallback domain.ProgressCallback) ([]WalletScore, error) {
 	walletScores := make(map[string]int)
 	walletToTokens := make(map[string][]TokenWithSource)
 	processedWallets := make(map[string]bool) // Track wallets we've already checked against the avoid list
+	avoidedWallets := make(map[string]bool)
 
 	// Count of valid tokens actually processed
 	validTokensProcessed := 0
 
 	for _, tokenSource := range tokenSources {
-		if progressCallback != nil {
-			sixBeforeEnd := len(tokenSource.MintAddress) - 6
-			progressCallback(fmt.Sprintf("Looking for wallets that interacted with token %s...%s", tokenSource.MintAddress[:6], tokenSource.MintAddress[sixBeforeEnd:]))
+		// Stop early if the guess was cancelled or timed out
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("wallet search interrupted: %w", err)
 		}
 
+		sixBeforeEnd := len(tokenSource.MintAddress) - 6
+		progressCallback.Report(fmt.Sprintf("Looking for wallets that interacted with token %s...%s", tokenSource.MintAddress[:6], tokenSource.MintAddress[sixBeforeEnd:]))
+
 		// Get all wallets that have interacted with this token
-		wallets, err := wg.blockchainClient.GetWalletsForToken(tokenSource.MintAddress, progressCallback)
+		wallets, err := wg.blockchainClient.GetWalletsForToken(ctx, tokenSource.MintAddress, progressCallback)
 		if err != nil {
-			log.Errorf("Error getting wallets for token %s: %v", tokenSource.MintAddress, err)
+			logging.FromContext(ctx).WithField(logging.FieldMint, tokenSource.MintAddress).Errorf("Error getting wallets for token: %v", err)
 			continue
 		}
 
@@ -101,11 +100,15 @@ func (wg *WalletGuesser) findWalletsForTokens(tokenSources []TokenWithSource, pr
 
 				// Check if the wallet should be avoided
 				if wg.avoidListService != nil {
-					if shouldAvoid, _ := wg.avoidListService.ShouldAvoid(wallet); shouldAvoid {
-						continue
+					if shouldAvoid, reason := wg.avoidListService.ShouldAvoid(wallet); shouldAvoid {
+						avoidedWallets[wallet] = true
+						progressCallback.ReportExclusion(fmt.Sprintf("Skipping wallet %s: %s", wallet, reason), reason)
 					}
 				}
 			}
+			if avoidedWallets[wallet] {
+				continue
+			}
 
 			// Increment score and associate this token with the wallet
 			walletScores[wallet]++
@@ -113,12 +116,10 @@ func (wg *WalletGuesser) findWalletsForTokens(tokenSources []TokenWithSource, pr
 		}
 	}
 
-	if progressCallback != nil {
-		processedCount := len(tokenSources)
-		if validTokensProcessed < processedCount {
-			progressCallback(fmt.Sprintf("Processed %d tokens, but only %d had valid results",
-				processedCount, validTokensProcessed))
-		}
+	processedCount := len(tokenSources)
+	if validTokensProcessed < processedCount {
+		progressCallback.Report(fmt.Sprintf("Processed %d tokens, but only %d had valid results",
+			processedCount, validTokensProcessed))
 	}
 
 	// Convert to sorted slice
@@ -137,7 +138,7 @@ func (wg *WalletGuesser) findWalletsForTokens(tokenSources []TokenWithSource, pr
 		return rankedWallets[i].Score > rankedWallets[j].Score
 	})
 
-	return rankedWallets
+	return rankedWallets, nil
 }
 
 // processRankedWallets converts ranked wallets into the result format
@@ -155,8 +156,8 @@ func (wg *WalletGuesser) processRankedWallets(twitterHandle string, rankedWallet
 		tokenToSourceMap[ts.MintAddress] = ts.Source
 	}
 
-	// Take top results (limit to 5)
-	maxResults := 5
+	// Take top results
+	maxResults := wg.maxResults
 	if len(rankedWallets) < maxResults {
 		maxResults = len(rankedWallets)
 	}
@@ -0,0 +1,239 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"wallet-guesser/internal/domain"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Status is the lifecycle state of a guess job
+type Status string
+
+// Job statuses
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// EventType identifies the kind of event a job emits
+type EventType string
+
+// Job event types
+const (
+	EventProgress EventType = "progress"
+	EventResult   EventType = "result"
+	EventError    EventType = "error"
+)
+
+// Event is a progress message or the final outcome of a job
+type Event struct {
+	Type      EventType                 `json:"type"`
+	Message   string                    `json:"message,omitempty"`
+	Exclusion *domain.AvoidReason       `json:"exclusion,omitempty"`
+	Result    *domain.WalletGuessResult `json:"result,omitempty"`
+	At        time.Time                 `json:"at"`
+}
+
+// ProgressEntry is a single message in a job's progress log
+type ProgressEntry struct {
+	Message   string              `json:"message"`
+	Exclusion *domain.AvoidReason `json:"exclusion,omitempty"`
+	At        time.Time           `json:"at"`
+}
+
+// event returns the progress event for the entry
+func (e ProgressEntry) event() Event {
+	return Event{Type: EventProgress, Message: e.Message, Exclusion: e.Exclusion, At: e.At}
+}
+
+// Snapshot is a point-in-time view of a job, safe to serialise
+type Snapshot struct {
+	ID            string                    `json:"id"`
+	CorrelationID string                    `json:"correlationId"`
+	TwitterHandle string                    `json:"twitterHandle"`
+	Status        Status                    `json:"status"`
+	Progress      []ProgressEntry           `json:"progress"`
+	Result        *domain.WalletGuessResult `json:"result,omitempty"`
+	Error         string                    `json:"error,omitempty"`
+	CreatedAt     time.Time                 `json:"createdAt"`
+	StartedAt     *time.Time                `json:"startedAt,omitempty"`
+	FinishedAt    *time.Time                `json:"finishedAt,omitempty"`
+}
+
+// subscriberBufferSize is how many events a slow subscriber may fall behind before
+// progress events are dropped for it
+const subscriberBufferSize = 64
+
+// Job is a single wallet guess run outside of a game session
+type Job struct {
+	ID            string
+	CorrelationID string
+	TwitterHandle string
+
+	mutex            sync.Mutex
+	status           Status
+	progress         []ProgressEntry
+	result           *domain.WalletGuessResult
+	err              string
+	createdAt        time.Time
+	startedAt        time.Time
+	finishedAt       time.Time
+	subscribers      map[int]chan Event
+	nextSubscriberID int
+
+	// ephemeral jobs are awaited by their submitter, such as a game session, and
+	// are neither persisted nor listed since nobody else can ask for them
+	ephemeral bool
+
+	// spanContext and logFields link the worker's spans and log lines to whoever submitted the job
+	spanContext trace.SpanContext
+	logFields   log.Fields
+}
+
+// newJob creates a queued job
+func newJob(id string, twitterHandle string) *Job {
+	return &Job{
+		ID:            id,
+		TwitterHandle: twitterHandle,
+		status:        StatusQueued,
+		createdAt:     time.Now(),
+		subscribers:   make(map[int]chan Event),
+	}
+}
+
+// Snapshot returns a copy of the job's current state
+func (j *Job) Snapshot() Snapshot {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	snapshot := Snapshot{
+		ID:            j.ID,
+		CorrelationID: j.CorrelationID,
+		TwitterHandle: j.TwitterHandle,
+		Status:        j.status,
+		Progress:      append([]ProgressEntry{}, j.progress...),
+		Result:        j.result,
+		Error:         j.err,
+		CreatedAt:     j.createdAt,
+	}
+	if !j.startedAt.IsZero() {
+		startedAt := j.startedAt
+		snapshot.StartedAt = &startedAt
+	}
+	if !j.finishedAt.IsZero() {
+		finishedAt := j.finishedAt
+		snapshot.FinishedAt = &finishedAt
+	}
+	return snapshot
+}
+
+// finishedBefore reports whether the job finished before cutoff
+func (j *Job) finishedBefore(cutoff time.Time) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return !j.finishedAt.IsZero() && j.finishedAt.Before(cutoff)
+}
+
+// Subscribe returns the events emitted so far and a channel that receives the
+// rest. The channel is closed once the job finishes; call cancel to stop early.
+func (j *Job) Subscribe() (replay []Event, events <-chan Event, cancel func()) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for _, entry := range j.progress {
+		replay = append(replay, entry.event())
+	}
+
+	ch := make(chan Event, subscriberBufferSize)
+	if j.isFinishedLocked() {
+		replay = append(replay, j.outcomeLocked())
+		close(ch)
+		return replay, ch, func() {}
+	}
+
+	id := j.nextSubscriberID
+	j.nextSubscriberID++
+	j.subscribers[id] = ch
+
+	return replay, ch, func() {
+		j.mutex.Lock()
+		defer j.mutex.Unlock()
+		if subscriber, ok := j.subscribers[id]; ok {
+			delete(j.subscribers, id)
+			close(subscriber)
+		}
+	}
+}
+
+// markRunning records that a worker has started the job
+func (j *Job) markRunning() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.status = StatusRunning
+	j.startedAt = time.Now()
+}
+
+// addProgress appends a progress update and notifies subscribers
+func (j *Job) addProgress(update domain.ProgressUpdate) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry := ProgressEntry{Message: update.Message, Exclusion: update.Exclusion, At: time.Now()}
+	j.progress = append(j.progress, entry)
+
+	event := entry.event()
+	for _, subscriber := range j.subscribers {
+		// Progress is best-effort for subscribers that fall behind; the log keeps everything
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+}
+
+// finish records the job's outcome and closes every subscriber channel
+func (j *Job) finish(result *domain.WalletGuessResult, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.finishedAt = time.Now()
+	if err != nil {
+		j.status = StatusFailed
+		j.err = err.Error()
+	} else {
+		j.status = StatusSucceeded
+		j.result = result
+	}
+
+	outcome := j.outcomeLocked()
+	for id, subscriber := range j.subscribers {
+		// The outcome must not be lost, so make room for it if the subscriber is behind
+		select {
+		case subscriber <- outcome:
+		default:
+			<-subscriber
+			subscriber <- outcome
+		}
+		close(subscriber)
+		delete(j.subscribers, id)
+	}
+}
+
+// isFinishedLocked reports whether the job has completed. The caller must hold the mutex.
+func (j *Job) isFinishedLocked() bool {
+	return j.status == StatusSucceeded || j.status == StatusFailed
+}
+
+// outcomeLocked builds the final event of a finished job. The caller must hold the mutex.
+func (j *Job) outcomeLocked() Event {
+	if j.status == StatusFailed {
+		return Event{Type: EventError, Message: j.err, At: j.finishedAt}
+	}
+	return Event{Type: EventResult, Result: j.result, At: j.finishedAt}
+}
@@ -0,0 +1,443 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/tracing"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultRetention is how long finished jobs are kept for status queries
+	DefaultRetention = time.Hour
+	// DefaultWorkers is the number of guesses run concurrently
+	DefaultWorkers = 4
+	// DefaultMaxQueued is the number of jobs allowed to wait for a worker
+	DefaultMaxQueued = 100
+	// DefaultJobTimeout bounds how long a single guess may run
+	DefaultJobTimeout = 3 * time.Minute
+)
+
+// ErrQueueFull is returned when no more jobs can be queued
+var ErrQueueFull = errors.New("guess queue is full, please try again later")
+
+// ErrShuttingDown is returned when a job is submitted after Shutdown
+var ErrShuttingDown = errors.New("the Jinn is going to sleep, please try again later")
+
+// Options configures a job manager
+type Options struct {
+	Workers    int
+	MaxQueued  int
+	JobTimeout time.Duration
+	Retention  time.Duration
+	StorePath  string // file used to persist unfinished jobs; empty disables persistence
+}
+
+// Manager runs wallet guesses as jobs on a bounded worker pool. Jobs wait in a
+// FIFO queue and are told their position in line through their progress stream.
+type Manager struct {
+	walletGuesserSvc domain.WalletGuesserService
+	options          Options
+	store            *store
+	jobs             map[string]*Job
+	unfinished       map[string]*Job // queued and running jobs that survive a restart
+	queue            []*Job
+	running          int
+	stopping         bool
+	mutex            sync.Mutex
+	queueCond        *sync.Cond
+	workers          sync.WaitGroup
+	persistMutex     sync.Mutex // keeps snapshots from being saved out of order
+}
+
+// NewManager creates a job manager, restores persisted jobs and starts its workers
+func NewManager(walletGuesserSvc domain.WalletGuesserService, options Options) *Manager {
+	if options.Workers <= 0 {
+		options.Workers = DefaultWorkers
+	}
+	if options.MaxQueued <= 0 {
+		options.MaxQueued = DefaultMaxQueued
+	}
+	if options.JobTimeout <= 0 {
+		options.JobTimeout = DefaultJobTimeout
+	}
+	if options.Retention <= 0 {
+		options.Retention = DefaultRetention
+	}
+
+	m := &Manager{
+		walletGuesserSvc: walletGuesserSvc,
+		options:          options,
+		jobs:             make(map[string]*Job),
+		unfinished:       make(map[string]*Job),
+	}
+	m.queueCond = sync.NewCond(&m.mutex)
+
+	if options.StorePath != "" {
+		m.store = newStore(options.StorePath)
+		m.restore()
+	}
+
+	m.workers.Add(options.Workers)
+	for i := 0; i < options.Workers; i++ {
+		go m.worker()
+	}
+
+	return m
+}
+
+// Submit queues a job for a Twitter handle. The job's spans and log lines join the
+// trace and correlation id in ctx, but ctx does not bound how long the job runs.
+// The job can be looked up by id and is re-queued if the server restarts first.
+func (m *Manager) Submit(ctx context.Context, twitterHandle string) (*Job, error) {
+	return m.submit(ctx, twitterHandle, false)
+}
+
+// submit queues a job. Ephemeral jobs are only known to their submitter.
+func (m *Manager) submit(ctx context.Context, twitterHandle string, ephemeral bool) (*Job, error) {
+	twitterHandle = strings.TrimPrefix(strings.TrimSpace(twitterHandle), "@")
+	if twitterHandle == "" {
+		return nil, fmt.Errorf("twitter handle is required")
+	}
+
+	job := newJob(newJobID(), twitterHandle)
+	job.ephemeral = ephemeral
+	job.spanContext = trace.SpanContextFromContext(ctx)
+	job.logFields = logging.Fields(ctx)
+	job.CorrelationID = logging.CorrelationID(ctx)
+	if job.CorrelationID == "" {
+		job.CorrelationID = job.ID
+	}
+
+	m.mutex.Lock()
+	if m.stopping {
+		m.mutex.Unlock()
+		return nil, ErrShuttingDown
+	}
+	if len(m.queue) >= m.options.MaxQueued {
+		m.mutex.Unlock()
+		return nil, ErrQueueFull
+	}
+	if !ephemeral {
+		m.pruneLocked()
+		m.jobs[job.ID] = job
+		m.unfinished[job.ID] = job
+	}
+	m.enqueueLocked(job)
+	m.mutex.Unlock()
+
+	if !ephemeral {
+		m.persist()
+	}
+	return job, nil
+}
+
+// GuessWallet submits a job and waits for it, forwarding its progress. It lets
+// callers that expect a synchronous guess, such as game sessions, share the queue.
+// The job is ephemeral: nobody could read its result after a restart, so it is
+// not persisted.
+func (m *Manager) GuessWallet(ctx context.Context, twitterHandle string, progressCallback domain.ProgressCallback) (*domain.WalletGuessResult, error) {
+	job, err := m.submit(ctx, twitterHandle, true)
+	if err != nil {
+		return nil, err
+	}
+
+	replay, events, cancel := job.Subscribe()
+	defer cancel()
+
+	handle := func(event Event) (bool, *domain.WalletGuessResult, error) {
+		switch event.Type {
+		case EventResult:
+			return true, event.Result, nil
+		case EventError:
+			return true, nil, errors.New(event.Message)
+		default:
+			if progressCallback != nil {
+				progressCallback(domain.ProgressUpdate{Message: event.Message, Exclusion: event.Exclusion})
+			}
+			return false, nil, nil
+		}
+	}
+
+	for _, event := range replay {
+		if done, result, err := handle(event); done {
+			return result, err
+		}
+	}
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				snapshot := job.Snapshot()
+				if snapshot.Error != "" {
+					return nil, errors.New(snapshot.Error)
+				}
+				return snapshot.Result, nil
+			}
+			if done, result, err := handle(event); done {
+				return result, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Get returns a job by id
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+// Position returns a job's 1-based place in the queue, or 0 if it is not waiting
+func (m *Manager) Position(id string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, job := range m.queue {
+		if job.ID == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// StopAccepting refuses new jobs and stops workers from starting queued ones,
+// while running jobs carry on. Queued jobs stay persisted and are re-queued on
+// the next start; queued ephemeral jobs fail, since nobody will wait for them
+// after a restart. Call it before draining connections, so nothing new starts
+// while they close. It is safe to call more than once.
+func (m *Manager) StopAccepting() {
+	m.mutex.Lock()
+	if m.stopping {
+		m.mutex.Unlock()
+		return
+	}
+	m.stopping = true
+	var kept, dropped []*Job
+	for _, job := range m.queue {
+		if job.ephemeral {
+			dropped = append(dropped, job)
+		} else {
+			kept = append(kept, job)
+		}
+	}
+	m.queue = kept
+	m.queueCond.Broadcast()
+	m.mutex.Unlock()
+
+	for _, job := range dropped {
+		job.finish(nil, ErrShuttingDown)
+	}
+	if len(kept) > 0 {
+		log.Infof("Leaving %d queued guess jobs for the next start", len(kept))
+	}
+}
+
+// Shutdown stops accepting jobs and waits for running ones to finish or for
+// ctx to expire. Unfinished jobs stay persisted and are re-queued on the next start.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.StopAccepting()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("running guess jobs did not finish: %w", ctx.Err())
+	}
+
+	m.persist()
+	return err
+}
+
+// worker runs queued jobs one at a time until the manager shuts down
+func (m *Manager) worker() {
+	defer m.workers.Done()
+
+	for {
+		m.mutex.Lock()
+		for len(m.queue) == 0 && !m.stopping {
+			m.queueCond.Wait()
+		}
+		if m.stopping {
+			m.mutex.Unlock()
+			return
+		}
+		job := m.queue[0]
+		m.queue = m.queue[1:]
+		m.running++
+		job.markRunning()
+		m.announcePositionsLocked()
+		m.mutex.Unlock()
+
+		m.run(job)
+
+		m.mutex.Lock()
+		m.running--
+		delete(m.unfinished, job.ID)
+		m.mutex.Unlock()
+		if !job.ephemeral {
+			m.persist()
+		}
+	}
+}
+
+// run executes a job's wallet guess under the per-job timeout
+func (m *Manager) run(job *Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.options.JobTimeout)
+	defer cancel()
+
+	ctx = trace.ContextWithSpanContext(ctx, job.spanContext)
+	ctx, span := tracing.Start(ctx, "jobs.run",
+		attribute.String("job.id", job.ID),
+		attribute.String("twitter.handle", job.TwitterHandle),
+	)
+	ctx = logging.WithFields(ctx, job.logFields)
+	ctx = logging.WithFields(ctx, log.Fields{
+		logging.FieldCorrelationID: job.CorrelationID,
+		logging.FieldJob:           job.ID,
+		logging.FieldHandle:        job.TwitterHandle,
+	})
+	logger := logging.FromContext(ctx)
+
+	progressCallback := func(update domain.ProgressUpdate) {
+		logger.Debugf("Progress: %s", update.Message)
+		job.addProgress(update)
+	}
+
+	result, err := m.walletGuesserSvc.GuessWallet(ctx, job.TwitterHandle, progressCallback)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("guess timed out after %s", m.options.JobTimeout)
+	}
+	if err != nil {
+		logger.Errorf("Guess job failed: %v", err)
+	}
+	tracing.End(span, err)
+	job.finish(result, err)
+}
+
+// enqueueLocked adds a job to the back of the queue and wakes a worker.
+// The caller must hold the mutex.
+func (m *Manager) enqueueLocked(job *Job) {
+	m.queue = append(m.queue, job)
+
+	// Only jobs that will actually wait for a worker are told their place in line
+	if idleWorkers := m.options.Workers - m.running; len(m.queue) > idleWorkers {
+		job.addProgress(domain.ProgressUpdate{Message: positionMessage(len(m.queue))})
+	}
+	m.queueCond.Signal()
+}
+
+// announcePositionsLocked tells every waiting job its new place in line once
+// all workers are busy. The caller must hold the mutex.
+func (m *Manager) announcePositionsLocked() {
+	if m.running < m.options.Workers {
+		return
+	}
+	for i, job := range m.queue {
+		job.addProgress(domain.ProgressUpdate{Message: positionMessage(i + 1)})
+	}
+}
+
+// positionMessage describes a job's place in the queue
+func positionMessage(position int) string {
+	return fmt.Sprintf("The Jinn is busy with other seekers. You are #%d in line.", position)
+}
+
+// pruneLocked removes finished jobs older than the retention period.
+// The caller must hold the mutex.
+func (m *Manager) pruneLocked() {
+	cutoff := time.Now().Add(-m.options.Retention)
+	for id, job := range m.jobs {
+		if job.finishedBefore(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// persist saves unfinished jobs so they can be resumed after a restart
+func (m *Manager) persist() {
+	if m.store == nil {
+		return
+	}
+
+	m.persistMutex.Lock()
+	defer m.persistMutex.Unlock()
+
+	m.mutex.Lock()
+	pending := make([]storedJob, 0, len(m.unfinished))
+	for _, job := range m.unfinished {
+		pending = append(pending, storedJob{
+			ID:            job.ID,
+			TwitterHandle: job.TwitterHandle,
+			CreatedAt:     job.createdAt,
+		})
+	}
+	m.mutex.Unlock()
+
+	// Keep the queue order for the next start
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+
+	if err := m.store.save(pending); err != nil {
+		log.Errorf("Failed to persist guess jobs: %v", err)
+	}
+}
+
+// restore re-queues jobs that were queued or running when the server stopped
+func (m *Manager) restore() {
+	pending, err := m.store.load()
+	if err != nil {
+		log.Warnf("Could not restore guess jobs: %v", err)
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, stored := range pending {
+		job := newJob(stored.ID, stored.TwitterHandle)
+		job.createdAt = stored.CreatedAt
+		m.jobs[job.ID] = job
+		m.unfinished[job.ID] = job
+		m.queue = append(m.queue, job)
+	}
+	if len(pending) > 0 {
+		log.Infof("Restored %d queued guess jobs", len(pending))
+	}
+}
+
+// newJobID generates a random job identifier
+func newJobID() string {
+	idBytes := make([]byte, 12)
+	if _, err := rand.Read(idBytes); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(idBytes)
+}
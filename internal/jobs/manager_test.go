@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"wallet-guesser/internal/domain"
+)
+
+// blockingGuesser records the handles it is asked for and holds each guess until release is closed
+type blockingGuesser struct {
+	mutex   sync.Mutex
+	handles []string
+	started chan string
+	release chan struct{}
+}
+
+func newBlockingGuesser() *blockingGuesser {
+	return &blockingGuesser{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+func (g *blockingGuesser) GuessWallet(ctx context.Context, twitterHandle string, _ domain.ProgressCallback) (*domain.WalletGuessResult, error) {
+	g.mutex.Lock()
+	g.handles = append(g.handles, twitterHandle)
+	g.mutex.Unlock()
+	g.started <- twitterHandle
+	<-g.release
+	return &domain.WalletGuessResult{TwitterHandle: twitterHandle}, nil
+}
+
+func (g *blockingGuesser) Handles() []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]string(nil), g.handles...)
+}
+
+func (g *blockingGuesser) ClearCache()                        {}
+func (g *blockingGuesser) ClearHandleCache(string) bool       { return false }
+func (g *blockingGuesser) ClearTokenCache(string) bool        { return false }
+func (g *blockingGuesser) CacheStats() map[string]interface{} { return nil }
+
+func waitStarted(t *testing.T, guesser *blockingGuesser) string {
+	t.Helper()
+	select {
+	case handle := <-guesser.started:
+		return handle
+	case <-time.After(5 * time.Second):
+		t.Fatal("guess did not start")
+		return ""
+	}
+}
+
+// waitQueued waits until count jobs are waiting for a worker
+func waitQueued(t *testing.T, m *Manager, count int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mutex.Lock()
+		queued := len(m.queue)
+		m.mutex.Unlock()
+		if queued >= count {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d jobs queued, want %d", queued, count)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func storedHandles(t *testing.T, path string) []string {
+	t.Helper()
+	stored, err := newStore(path).load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var handles []string
+	for _, job := range stored {
+		handles = append(handles, job.TwitterHandle)
+	}
+	return handles
+}
+
+func TestOnlySubmittedJobsArePersisted(t *testing.T) {
+	guesser := newBlockingGuesser()
+	storePath := filepath.Join(t.TempDir(), "jobs.json")
+	m := NewManager(guesser, Options{Workers: 1, StorePath: storePath})
+
+	if _, err := m.Submit(context.Background(), "alice"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	waitStarted(t, guesser)
+
+	// A game session's guess waits behind alice but is not written to the store
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.GuessWallet(context.Background(), "bob", nil)
+		done <- err
+	}()
+	if _, err := m.Submit(context.Background(), "carol"); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	if handles := storedHandles(t, storePath); len(handles) != 2 || handles[0] != "alice" || handles[1] != "carol" {
+		t.Fatalf("stored %v, want [alice carol]", handles)
+	}
+
+	close(guesser.release)
+	if err := <-done; err != nil {
+		t.Fatalf("GuessWallet: %v", err)
+	}
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if handles := storedHandles(t, storePath); len(handles) != 0 {
+		t.Fatalf("stored %v after every job finished", handles)
+	}
+}
+
+func TestShutdownNeverStartsQueuedJobs(t *testing.T) {
+	guesser := newBlockingGuesser()
+	storePath := filepath.Join(t.TempDir(), "jobs.json")
+	m := NewManager(guesser, Options{Workers: 1, StorePath: storePath})
+
+	for _, handle := range []string{"alice", "bob", "carol"} {
+		if _, err := m.Submit(context.Background(), handle); err != nil {
+			t.Fatalf("Submit %s: %v", handle, err)
+		}
+	}
+	waitStarted(t, guesser)
+
+	// A game session waiting in the queue is told the Jinn is going to sleep
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.GuessWallet(context.Background(), "dave", nil)
+		done <- err
+	}()
+	waitQueued(t, m, 3)
+
+	m.StopAccepting()
+	if _, err := m.Submit(context.Background(), "erin"); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("Submit after StopAccepting: err = %v, want %v", err, ErrShuttingDown)
+	}
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != ErrShuttingDown.Error() {
+			t.Fatalf("queued game guess: err = %v, want %v", err, ErrShuttingDown)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued game guess was not failed")
+	}
+
+	// Finishing the running job must not let the worker pick up the next one
+	close(guesser.release)
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if handles := guesser.Handles(); len(handles) != 1 || handles[0] != "alice" {
+		t.Fatalf("guessed %v, want only alice", handles)
+	}
+	if handles := storedHandles(t, storePath); len(handles) != 2 || handles[0] != "bob" || handles[1] != "carol" {
+		t.Fatalf("stored %v, want [bob carol]", handles)
+	}
+}
@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// storedJob is the persisted form of an unfinished job
+type storedJob struct {
+	ID            string    `json:"id"`
+	TwitterHandle string    `json:"twitterHandle"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// store persists unfinished jobs to a JSON file
+type store struct {
+	filePath string
+	mutex    sync.Mutex
+}
+
+// newStore creates a store backed by a file
+func newStore(filePath string) *store {
+	return &store{filePath: filePath}
+}
+
+// load reads persisted jobs; a missing file means there is nothing to restore
+func (s *store) load() ([]storedJob, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := os.ReadFile(s.filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job store: %w", err)
+	}
+
+	var fileData struct {
+		Jobs []storedJob `json:"jobs"`
+	}
+	if err := json.Unmarshal(data, &fileData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal job store: %w", err)
+	}
+	return fileData.Jobs, nil
+}
+
+// save atomically replaces the persisted jobs
+func (s *store) save(jobs []storedJob) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for job store: %w", err)
+	}
+
+	data, err := json.Marshal(struct {
+		Jobs []storedJob `json:"jobs"`
+	}{Jobs: jobs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal job store: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated store
+	tmpPath := s.filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.filePath); err != nil {
+		return fmt.Errorf("failed to replace job store: %w", err)
+	}
+	return nil
+}
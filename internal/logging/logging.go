@@ -0,0 +1,123 @@
+// Package logging configures logrus output and carries correlated log fields
+// through a context so every line about a guess can be tied back to it.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Field names shared by log lines across packages
+const (
+	FieldCorrelationID = "correlation_id"
+	FieldHandle        = "handle"
+	FieldMint          = "mint"
+	FieldSession       = "session"
+	FieldJob           = "job"
+	FieldRPCMethod     = "rpc_method"
+	FieldDuration      = "duration"
+)
+
+// Supported log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Configure sets the log format and level for the standard logger. Every
+// format redacts registered secrets and credentials in query strings.
+func Configure(format string, debug bool) error {
+	var formatter log.Formatter
+	switch format {
+	case "", FormatText:
+		formatter = &log.TextFormatter{FullTimestamp: true}
+	case FormatJSON:
+		formatter = &log.JSONFormatter{}
+	default:
+		return fmt.Errorf("unknown log format %q (expected %s or %s)", format, FormatText, FormatJSON)
+	}
+
+	log.SetOutput(os.Stdout)
+	log.SetFormatter(&redactingFormatter{formatter: formatter})
+	if debug {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		log.SetLevel(log.InfoLevel)
+	}
+	return nil
+}
+
+type fieldsContextKey struct{}
+
+// WithFields returns a context whose log entry carries fields in addition to those already in ctx
+func WithFields(ctx context.Context, fields log.Fields) context.Context {
+	existing, _ := ctx.Value(fieldsContextKey{}).(log.Fields)
+	merged := make(log.Fields, len(existing)+len(fields))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, fieldsContextKey{}, merged)
+}
+
+// WithField returns a context whose log entry carries one more field
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return WithFields(ctx, log.Fields{key: value})
+}
+
+// Fields returns the log fields carried by ctx
+func Fields(ctx context.Context) log.Fields {
+	fields, _ := ctx.Value(fieldsContextKey{}).(log.Fields)
+	return fields
+}
+
+// Value returns a string field carried by ctx, or "" if it is not set
+func Value(ctx context.Context, key string) string {
+	value, _ := Fields(ctx)[key].(string)
+	return value
+}
+
+// FromContext returns a log entry with the fields carried by ctx
+func FromContext(ctx context.Context) *log.Entry {
+	return log.WithFields(Fields(ctx))
+}
+
+// NewCorrelationID generates an id for tying together the log lines of one guess
+func NewCorrelationID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// WithCorrelationID returns a context carrying the correlation id
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return WithField(ctx, FieldCorrelationID, correlationID)
+}
+
+// CorrelationID returns the correlation id carried by ctx, or "" if there is none
+func CorrelationID(ctx context.Context) string {
+	return Value(ctx, FieldCorrelationID)
+}
+
+// EnsureCorrelationID returns ctx unchanged if it has a correlation id, or with a new one otherwise
+func EnsureCorrelationID(ctx context.Context) context.Context {
+	if CorrelationID(ctx) != "" {
+		return ctx
+	}
+	return WithCorrelationID(ctx, NewCorrelationID())
+}
+
+// Duration formats an elapsed time for the duration field
+func Duration(start time.Time) string {
+	return time.Since(start).Round(time.Millisecond).String()
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const redacted = "[REDACTED]"
+
+// credentialParamPattern matches credentials passed as query parameters, e.g. in request URLs quoted by errors
+var credentialParamPattern = regexp.MustCompile(`(?i)((?:api[-_]?key|token|access[-_]?token|secret)=)[^&\s"'\\]+`)
+
+var (
+	secretsMutex sync.RWMutex
+	secrets      [][]byte
+)
+
+// RegisterSecret makes the formatter redact a value wherever it appears in a log line
+func RegisterSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+	secrets = append(secrets, []byte(secret))
+}
+
+// Redact removes registered secrets and query string credentials from s
+func Redact(s string) string {
+	return string(redact([]byte(s)))
+}
+
+// redact removes registered secrets and query string credentials from a formatted line
+func redact(line []byte) []byte {
+	secretsMutex.RLock()
+	for _, secret := range secrets {
+		line = bytes.ReplaceAll(line, secret, []byte(redacted))
+	}
+	secretsMutex.RUnlock()
+
+	return credentialParamPattern.ReplaceAll(line, []byte("${1}"+redacted))
+}
+
+// redactingFormatter redacts the output of another formatter
+type redactingFormatter struct {
+	formatter log.Formatter
+}
+
+// Format implements log.Formatter
+func (f *redactingFormatter) Format(entry *log.Entry) ([]byte, error) {
+	line, err := f.formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return redact(line), nil
+}
@@ -0,0 +1,109 @@
+// Package metrics defines the Prometheus metrics exported by the server on /metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "wallet_guesser"
+
+// Outcome labels shared by the duration histograms
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeCached  = "cached"
+	OutcomeAvoided = "avoided"
+)
+
+var (
+	// GuessDuration measures end-to-end GuessWallet latency
+	GuessDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "guess_duration_seconds",
+		Help:      "End-to-end duration of GuessWallet.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"outcome"})
+
+	// FetchFollowingDuration measures how long Apify takes to return followed accounts
+	FetchFollowingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "fetch_following_duration_seconds",
+		Help:      "Duration of Twitter FetchFollowing calls via Apify.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"outcome"})
+
+	// GetWalletsForTokenDuration measures holder lookups for a single token
+	GetWalletsForTokenDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "get_wallets_for_token_duration_seconds",
+		Help:      "Duration of GetWalletsForToken calls.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"outcome"})
+
+	// RPCRequests counts Solana JSON-RPC calls
+	RPCRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rpc_requests_total",
+		Help:      "Solana JSON-RPC requests by method and status.",
+	}, []string{"method", "status"})
+
+	// CacheRequests counts cache lookups
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	// AvoidListSkips counts addresses excluded by the avoid list
+	AvoidListSkips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "avoid_list_skips_total",
+		Help:      "Addresses skipped because they are on the avoid list, by entry type and category.",
+	}, []string{"type", "category"})
+
+	// DuneRequests counts Dune API calls
+	DuneRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dune_requests_total",
+		Help:      "Dune API requests by operation and status.",
+	}, []string{"operation", "status"})
+
+	// AvoidListRefreshes counts avoid list refreshes from Dune
+	AvoidListRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "avoid_list_refreshes_total",
+		Help:      "Avoid list refreshes from Dune, by outcome.",
+	}, []string{"outcome"})
+
+	// ActiveWebSocketConnections tracks open game connections
+	ActiveWebSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections_active",
+		Help:      "Number of open WebSocket connections.",
+	})
+
+	// ConfidenceScore records the confidence of each completed guess
+	ConfidenceScore = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "guess_confidence_score",
+		Help:      "Distribution of confidence scores (0-100) of guesses that found addresses.",
+		Buckets:   prometheus.LinearBuckets(10, 10, 10),
+	})
+)
+
+// ObserveDuration records the time since start in a duration histogram
+func ObserveDuration(histogram *prometheus.HistogramVec, outcome string, start time.Time) {
+	histogram.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// CacheLookup records a cache hit or miss
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(cache, result).Inc()
+}
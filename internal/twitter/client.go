@@ -2,6 +2,7 @@ package twitter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,13 +12,21 @@ import (
 	"time"
 
 	"wallet-guesser/internal/domain"
+	"wallet-guesser/internal/logging"
+	"wallet-guesser/internal/metrics"
+	"wallet-guesser/internal/tracing"
 
-	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// DefaultActorURL is the Apify actor that scrapes the accounts a user follows
+const DefaultActorURL = "https://api.apify.com/v2/acts/kaitoeasyapi~premium-x-follower-scraper-following-data/run-sync-get-dataset-items"
+
 // Client handles interactions with the Twitter API via Apify
 type Client struct {
 	apifyToken   string
+	actorURL     string
+	scanWebsites bool
 	httpClient   *http.Client
 	timeout      int
 	websiteCache map[string]string // URL -> content
@@ -27,6 +36,7 @@ type Client struct {
 // NewClient creates a new Twitter API client
 func NewClient(options ...ClientOption) domain.TwitterService {
 	client := &Client{
+		actorURL:     DefaultActorURL,
 		timeout:      30, // Default timeout in seconds
 		websiteCache: make(map[string]string),
 	}
@@ -44,14 +54,34 @@ func NewClient(options ...ClientOption) domain.TwitterService {
 }
 
 // FetchFollowing fetches the accounts a user is following via Apify
-func (c *Client) FetchFollowing(username string, limit int, progressCallback domain.ProgressCallback) ([]domain.TwitterUser, error) {
+func (c *Client) FetchFollowing(ctx context.Context, username string, limit int, progressCallback domain.ProgressCallback) ([]domain.TwitterUser, error) {
+	ctx, span := tracing.Start(ctx, "twitter.FetchFollowing",
+		attribute.String("twitter.handle", username),
+		attribute.Int("twitter.limit", limit),
+	)
+	ctx = logging.WithField(ctx, logging.FieldHandle, username)
+	start := time.Now()
+	users, err := c.fetchFollowing(ctx, username, limit, progressCallback)
+	span.SetAttributes(attribute.Int("twitter.following", len(users)))
+	tracing.End(span, err)
+	entry := logging.FromContext(ctx).WithField(logging.FieldDuration, logging.Duration(start))
+	if err != nil {
+		entry.Warnf("Apify request for accounts followed failed: %v", err)
+		metrics.ObserveDuration(metrics.FetchFollowingDuration, metrics.OutcomeError, start)
+		return nil, err
+	}
+	entry.Infof("Fetched %d accounts followed", len(users))
+	metrics.ObserveDuration(metrics.FetchFollowingDuration, metrics.OutcomeSuccess, start)
+	return users, nil
+}
+
+// fetchFollowing performs the Apify request for FetchFollowing
+func (c *Client) fetchFollowing(ctx context.Context, username string, limit int, progressCallback domain.ProgressCallback) ([]domain.TwitterUser, error) {
 	if c.apifyToken == "" {
 		return nil, errors.New("apify token is not set")
 	}
 
-	if progressCallback != nil {
-		progressCallback(fmt.Sprintf("Fetching users followed by @%s...", username))
-	}
+	progressCallback.Report(fmt.Sprintf("Fetching users followed by @%s...", username))
 
 	// Prepare the Apify API request
 	apifyInput := ApifyInput{
@@ -68,23 +98,16 @@ func (c *Client) FetchFollowing(username string, limit int, progressCallback dom
 	}
 
 	// Create the request
-	apiURL := "https://api.apify.com/v2/acts/kaitoeasyapi~premium-x-follower-scraper-following-data/run-sync-get-dataset-items"
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(inputJSON))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.actorURL, bytes.NewBuffer(inputJSON))
 	if err != nil {
 		return nil, err
 	}
 
-	// Set headers
+	// Set headers. The token goes in a header so it never appears in URLs quoted by errors.
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apifyToken)
 
-	// Add token as query parameter
-	q := req.URL.Query()
-	q.Add("token", c.apifyToken)
-	req.URL.RawQuery = q.Encode()
-
-	if progressCallback != nil {
-		progressCallback("Sending request to Apify...")
-	}
+	progressCallback.Report("Sending request to Apify...")
 
 	// Make the request
 	resp, err := c.httpClient.Do(req)
@@ -98,9 +121,7 @@ func (c *Client) FetchFollowing(username string, limit int, progressCallback dom
 		return nil, fmt.Errorf("apify API error (status %d): %s", resp.StatusCode, string(bodyBytes[:min(100, len(bodyBytes))]))
 	}
 
-	if progressCallback != nil {
-		progressCallback("Processing Apify response...")
-	}
+	progressCallback.Report("Processing Apify response...")
 
 	responseContent, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -119,15 +140,13 @@ func (c *Client) FetchFollowing(username string, limit int, progressCallback dom
 		// Process account
 		user, err := c.processTwitterAccount(accountResp, progressCallback)
 		if err != nil {
-			log.Warnf("Error processing account @%s: %v", accountResp.Username, err)
+			logging.FromContext(ctx).Warnf("Error processing account @%s: %v", accountResp.Username, err)
 			continue
 		}
 		users = append(users, user)
 	}
 
-	if progressCallback != nil {
-		progressCallback(fmt.Sprintf("Successfully processed data for %d users followed by @%s", len(users), username))
-	}
+	progressCallback.Report(fmt.Sprintf("Successfully processed data for %d users followed by @%s", len(users), username))
 
 	return users, nil
 }
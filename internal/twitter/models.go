@@ -42,6 +42,20 @@ func WithApifyToken(token string) ClientOption {
 	}
 }
 
+// WithActorURL sets the Apify actor endpoint that returns followed accounts
+func WithActorURL(actorURL string) ClientOption {
+	return func(c *Client) {
+		c.actorURL = actorURL
+	}
+}
+
+// WithWebsiteScanning enables scanning followed accounts' websites for addresses
+func WithWebsiteScanning(enabled bool) ClientOption {
+	return func(c *Client) {
+		c.scanWebsites = enabled
+	}
+}
+
 // WithTimeout sets the HTTP client timeout
 func WithTimeout(timeout int) ClientOption {
 	return func(c *Client) {
@@ -46,32 +46,26 @@ func (c *Client) processTwitterAccount(accountResp ApifyFollowerResponse, progre
 	bioAddresses := ExtractSolanaAddresses(accountResp.Bio)
 	if len(bioAddresses) > 0 {
 		user.PossibleMintAddresses = append(user.PossibleMintAddresses, bioAddresses...)
-		if progressCallback != nil {
-			progressCallback(fmt.Sprintf("Found %d potential wallet address(es) in @%s's bio", len(bioAddresses), accountResp.Username))
-		}
+		progressCallback.Report(fmt.Sprintf("Found %d potential wallet address(es) in @%s's bio", len(bioAddresses), accountResp.Username))
 	}
 
 	// If a website is provided, fetch and scan it
 	for _, profileUrl := range user.Urls {
-		continue
+		if !c.scanWebsites {
+			break
+		}
 		if !isValidURL(profileUrl) {
 			continue
 		}
-		if progressCallback != nil {
-			progressCallback(fmt.Sprintf("Checking @%s's website: %s", accountResp.Username, profileUrl))
-		}
+		progressCallback.Report(fmt.Sprintf("Checking @%s's website: %s", accountResp.Username, profileUrl))
 
 		websiteAddresses, err := c.FetchAndExtractAddressesFromWebsite(profileUrl)
 		if err != nil {
 			// Just log the error but continue
-			if progressCallback != nil {
-				progressCallback(fmt.Sprintf("Error scanning website for @%s: %s", accountResp.Username, err.Error()))
-			}
+			progressCallback.Report(fmt.Sprintf("Error scanning website for @%s: %s", accountResp.Username, err.Error()))
 		} else if len(websiteAddresses) > 0 {
 			user.PossibleMintAddresses = append(user.PossibleMintAddresses, websiteAddresses...)
-			if progressCallback != nil {
-				progressCallback(fmt.Sprintf("Found %d potential wallet address(es) on @%s's website", len(websiteAddresses), accountResp.Username))
-			}
+			progressCallback.Report(fmt.Sprintf("Found %d potential wallet address(es) on @%s's website", len(websiteAddresses), accountResp.Username))
 		}
 	}
 
@@ -0,0 +1,176 @@
+package verification
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"wallet-guesser/internal/blockchain"
+	"wallet-guesser/internal/domain"
+)
+
+const (
+	// DefaultChallengeTTL is how long a player has to sign an issued nonce
+	DefaultChallengeTTL = 5 * time.Minute
+)
+
+// ErrWrongSession is returned when a challenge is redeemed by a session other than the one it was issued to
+var ErrWrongSession = errors.New("challenge was issued to another session")
+
+// Service implements domain.VerificationService using ed25519 signatures
+type Service struct {
+	challengeTTL time.Duration
+	challenges   map[string]domain.VerificationChallenge // nonce -> challenge
+	verified     map[string]domain.VerifiedGuess         // twitter handle -> verified guess
+	mutex        sync.Mutex
+}
+
+// NewService creates a new verification service
+func NewService(challengeTTL time.Duration) *Service {
+	if challengeTTL <= 0 {
+		challengeTTL = DefaultChallengeTTL
+	}
+
+	return &Service{
+		challengeTTL: challengeTTL,
+		challenges:   make(map[string]domain.VerificationChallenge),
+		verified:     make(map[string]domain.VerifiedGuess),
+	}
+}
+
+// IssueChallenge creates a nonce the player of a game session must sign with the guessed wallet
+func (s *Service) IssueChallenge(sessionID string, twitterHandle string, address string) (*domain.VerificationChallenge, error) {
+	if _, err := decodePublicKey(address); err != nil {
+		return nil, err
+	}
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+
+	challenge := domain.VerificationChallenge{
+		Nonce:         nonce,
+		SessionID:     sessionID,
+		Address:       address,
+		TwitterHandle: twitterHandle,
+		Message:       ChallengeMessage(twitterHandle, address, nonce),
+		ExpiresAt:     time.Now().Add(s.challengeTTL),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pruneExpiredLocked()
+	s.challenges[nonce] = challenge
+
+	return &challenge, nil
+}
+
+// VerifySignature checks a signature over a challenge previously issued to the
+// same session. A challenge can only be used once, whether or not the signature
+// is valid, but another session cannot use it up.
+func (s *Service) VerifySignature(sessionID string, nonce string, signature string) (*domain.VerifiedGuess, error) {
+	s.mutex.Lock()
+	challenge, ok := s.challenges[nonce]
+	if ok && challenge.SessionID != sessionID {
+		s.mutex.Unlock()
+		return nil, ErrWrongSession
+	}
+	delete(s.challenges, nonce)
+	s.mutex.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown or already used nonce")
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, fmt.Errorf("challenge expired at %s", challenge.ExpiresAt.Format(time.RFC3339))
+	}
+
+	publicKey, err := decodePublicKey(challenge.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureBytes, err := decodeSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(challenge.Message), signatureBytes) {
+		return nil, fmt.Errorf("signature does not match address %s", challenge.Address)
+	}
+
+	verified := domain.VerifiedGuess{
+		TwitterHandle: challenge.TwitterHandle,
+		Address:       challenge.Address,
+		VerifiedAt:    time.Now(),
+	}
+
+	s.mutex.Lock()
+	s.verified[challenge.TwitterHandle] = verified
+	s.mutex.Unlock()
+
+	return &verified, nil
+}
+
+// GetVerifiedGuess returns the verified guess for a Twitter handle, if any
+func (s *Service) GetVerifiedGuess(twitterHandle string) (*domain.VerifiedGuess, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	verified, ok := s.verified[twitterHandle]
+	if !ok {
+		return nil, false
+	}
+	return &verified, true
+}
+
+// pruneExpiredLocked removes expired challenges. The caller must hold the mutex.
+func (s *Service) pruneExpiredLocked() {
+	now := time.Now()
+	for nonce, challenge := range s.challenges {
+		if now.After(challenge.ExpiresAt) {
+			delete(s.challenges, nonce)
+		}
+	}
+}
+
+// ChallengeMessage builds the exact text the player's wallet must sign
+func ChallengeMessage(twitterHandle string, address string, nonce string) string {
+	return fmt.Sprintf("The Crypto Jinn asks you to prove ownership of this wallet.\n\nTwitter: @%s\nWallet: %s\nNonce: %s",
+		twitterHandle, address, nonce)
+}
+
+// decodePublicKey decodes a base58 Solana address into an ed25519 public key
+func decodePublicKey(address string) (ed25519.PublicKey, error) {
+	keyBytes, err := blockchain.DecodeBase58(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet address: %w", err)
+	}
+	if len(keyBytes) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid wallet address: expected %d bytes, got %d", ed25519.PublicKeySize, len(keyBytes))
+	}
+	return ed25519.PublicKey(keyBytes), nil
+}
+
+// decodeSignature accepts a base58 (wallet adapter default) or base64 encoded signature
+func decodeSignature(signature string) ([]byte, error) {
+	signature = strings.TrimSpace(signature)
+
+	if decoded, err := blockchain.DecodeBase58(signature); err == nil && len(decoded) == ed25519.SignatureSize {
+		return decoded, nil
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(signature); err == nil && len(decoded) == ed25519.SignatureSize {
+		return decoded, nil
+	}
+
+	return nil, fmt.Errorf("signature must be a base58 or base64 encoded %d-byte ed25519 signature", ed25519.SignatureSize)
+}
@@ -0,0 +1,44 @@
+package verification
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"wallet-guesser/internal/blockchain"
+)
+
+func TestVerifySignatureIsBoundToTheSession(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	address := blockchain.EncodeBase58(publicKey)
+
+	svc := NewService(DefaultChallengeTTL)
+	challenge, err := svc.IssueChallenge("session-a", "alice", address)
+	if err != nil {
+		t.Fatalf("IssueChallenge: %v", err)
+	}
+	signature := blockchain.EncodeBase58(ed25519.Sign(privateKey, []byte(challenge.Message)))
+
+	if _, err := svc.VerifySignature("session-b", challenge.Nonce, signature); !errors.Is(err, ErrWrongSession) {
+		t.Fatalf("redeeming from another session: err = %v, want %v", err, ErrWrongSession)
+	}
+	if _, ok := svc.GetVerifiedGuess("alice"); ok {
+		t.Fatal("another session's attempt recorded a verified guess")
+	}
+
+	// The other session's attempt must not use up the nonce
+	verified, err := svc.VerifySignature("session-a", challenge.Nonce, signature)
+	if err != nil {
+		t.Fatalf("VerifySignature: %v", err)
+	}
+	if verified.Address != address || verified.TwitterHandle != "alice" {
+		t.Fatalf("unexpected verified guess %+v", verified)
+	}
+	if _, err := svc.VerifySignature("session-a", challenge.Nonce, signature); err == nil {
+		t.Fatal("expected a used nonce to be rejected")
+	}
+}